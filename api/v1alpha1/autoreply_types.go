@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AutoReplySpec defines the desired state of AutoReply
+type AutoReplySpec struct {
+	// UserName is the name of the User resource in the namespace of the auto-reply whose replies are scheduled.
+	UserName string `json:"userName"`
+	// Subject is the template of the subject of auto-reply e-mails, unless a window sets its own.
+	Subject string `json:"subject,omitempty"`
+	// Body is the template of the body of auto-reply e-mails, unless a window sets its own.
+	Body string `json:"body,omitempty"`
+	// Windows are the periods in which auto-reply e-mails are sent. If windows overlap, the first one applies.
+	Windows []AutoReplyWindow `json:"windows,omitempty"`
+}
+
+// AutoReplyWindow is a period in which auto-reply e-mails are sent.
+type AutoReplyWindow struct {
+	// Start is the time from which on auto-reply e-mails are sent.
+	Start metav1.Time `json:"start"`
+	// End is the time until which auto-reply e-mails are sent.
+	End metav1.Time `json:"end"`
+	// Subject is the template of the subject within this window.
+	Subject string `json:"subject,omitempty"`
+	// Body is the template of the body within this window.
+	Body string `json:"body,omitempty"`
+}
+
+// AutoReplyStatus defines the observed state of AutoReply
+type AutoReplyStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// ObservedGeneration is the generation of the spec that was last applied to MailU.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// AppliedAddress is the address of the user the auto-reply was last applied to.
+	AppliedAddress string `json:"appliedAddress,omitempty"`
+	// ActiveWindow is the index of the window in which auto-reply e-mails are currently sent, if any.
+	ActiveWindow *int `json:"activeWindow,omitempty"`
+	// NextTransition is the time at which auto-replies are enabled or disabled next, if any.
+	NextTransition *metav1.Time `json:"nextTransition,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// AutoReply is the Schema for the autoreplies API
+type AutoReply struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AutoReplySpec   `json:"spec,omitempty"`
+	Status AutoReplyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AutoReplyList contains a list of AutoReply
+type AutoReplyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AutoReply `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AutoReply{}, &AutoReplyList{})
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ForwardingPolicySpec defines the domains Users may forward e-mail to.
+type ForwardingPolicySpec struct {
+	// NamespaceSelector selects the namespaces of the Users this policy applies to, all namespaces if not set.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// AllowedDomains are the patterns of the domains e-mail may be forwarded to, e.g. "example.com" or
+	// "*.example.com". All domains are allowed if empty.
+	// +optional
+	AllowedDomains []string `json:"allowedDomains,omitempty"`
+	// DeniedDomains are the patterns of the domains e-mail must not be forwarded to, even if they are allowed.
+	// +optional
+	DeniedDomains []string `json:"deniedDomains,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// ForwardingPolicy is the Schema for the forwardingpolicies API
+type ForwardingPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ForwardingPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ForwardingPolicyList contains a list of ForwardingPolicy
+type ForwardingPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ForwardingPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ForwardingPolicy{}, &ForwardingPolicyList{})
+}
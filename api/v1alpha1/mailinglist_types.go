@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MailingListSpec defines the desired state of MailingList
+type MailingListSpec struct {
+	// Name part of e-mail address 'name@domain'.
+	Name string `json:"name"`
+	// Domain part of e-mail address 'name@domain'.
+	Domain string `json:"domain"`
+	// Comment is a custom comment for the alias of the mailing list.
+	Comment string `json:"comment,omitempty"`
+	// Selector selects the Users in the namespace of the mailing list that are members. Only ready Users are added
+	// to the destinations. Without a selector, the mailing list has no User members.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// ExtraDestinations are added to the destinations in addition to the members.
+	ExtraDestinations []string `json:"extraDestinations,omitempty"`
+	// DeletionPolicy defines if the alias is deleted in MailU ('Delete') or kept ('Retain') when this resource is deleted.
+	// Defaults to the deletion policy of the operator.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// MailingListStatus defines the observed state of MailingList
+type MailingListStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// ObservedGeneration is the generation of the spec that was last applied to MailU.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Members are the addresses of the Users that were last applied as destinations.
+	Members []string `json:"members,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// MailingList is the Schema for the mailinglists API
+type MailingList struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MailingListSpec   `json:"spec,omitempty"`
+	Status MailingListStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MailingListList contains a list of MailingList
+type MailingListList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MailingList `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MailingList{}, &MailingListList{})
+}
@@ -26,9 +26,8 @@ type UserSpec struct {
 	Name string `json:"name"`
 	// Domain part of e-mail address 'name@domain'.
 	Domain string `json:"domain"`
-	// AllowSpoofing allows this user to send e-mails with any sender.
-	// +kubebuilder:default=false
-	AllowSpoofing bool `json:"allowSpoofing,omitempty"`
+	// UserSettings not set on the user are taken from its UserClass and the userDefaults of the Domain.
+	UserSettings `json:",inline"`
 	// ChangePassword requires the user to change the password on next login.
 	// +kubebuilder:default=false
 	ChangePassword bool `json:"changePassword,omitempty"`
@@ -40,12 +39,6 @@ type UserSpec struct {
 	// Enabled states the status of this user account.
 	// +kubebuilder:default=false
 	Enabled bool `json:"enabled,omitempty"`
-	// EnableIMAP states if IMAP is available to the user.
-	// +kubebuilder:default=false
-	EnableIMAP bool `json:"enableIMAP,omitempty"`
-	// EnablePOP states if POP3 is available to the user.
-	// +kubebuilder:default=false
-	EnablePOP bool `json:"enablePOP,omitempty"`
 	// ForwardEnabled states if e-mails are forwarded.
 	// +kubebuilder:default=false
 	ForwardEnabled bool `json:"forwardEnabled,omitempty"`
@@ -62,9 +55,6 @@ type UserSpec struct {
 	PasswordSecret string `json:"passwordSecret,omitempty"`
 	// PasswordKey is the key in the secret that contains the password.
 	PasswordKey string `json:"passwordKey,omitempty"`
-	// QuotaBytes defines the storage quota, default -1 for unlimited.
-	// +kubebuilder:default=-1
-	QuotaBytes int64 `json:"quotaBytes,omitempty"`
 	// RawPassword is the plaintext password for user creation.
 	RawPassword string `json:"rawPassword,omitempty"`
 	// ReplyEnabled states if e-mails should be auto-replied to.
@@ -84,25 +74,124 @@ type UserSpec struct {
 	// +kubebuilder:validation:Format=date
 	// +kubebuilder:default="2999-12-31"
 	ReplyEndDate string `json:"replyEndDate,omitempty"`
+	// Aliases are additional addresses of the user, created as aliases in MailU with the user as destination.
+	// Entries without a domain are in the domain of the user, e.g. 'firstname.lastname'.
+	Aliases []string `json:"aliases,omitempty"`
+	// UserClassName is the name of a UserClass, whose settings apply to the settings not set on the user. They take
+	// precedence over the userDefaults of the Domain.
+	UserClassName string `json:"userClassName,omitempty"`
+	// ActiveFrom is the time from which on the user is enabled in MailU. Before, it is disabled.
+	ActiveFrom *metav1.Time `json:"activeFrom,omitempty"`
+	// ActiveUntil is the time at which the user expires and the expiryAction applies.
+	ActiveUntil *metav1.Time `json:"activeUntil,omitempty"`
+	// ExpiryAction defines if the user is disabled ('Disable', the default) or deleted ('Delete') in MailU once it
+	// expired. The resource itself is kept.
+	ExpiryAction UserExpiryAction `json:"expiryAction,omitempty"`
+	// IgnoreFields lists fields which are only set on creation and not updated afterwards, so they can be
+	// changed in the Mailu UI. Wildcards are supported, e.g. 'reply*' or 'forward*'.
+	IgnoreFields []string `json:"ignoreFields,omitempty"`
+	// DeletionPolicy defines if the user is deleted in MailU ('Delete') or kept ('Retain') when this resource is deleted.
+	// Defaults to the deletion policy of the operator.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// AdoptionPolicy defines if an existing user in MailU is taken over ('Adopt'), never taken over ('FailIfExists')
+	// or only taken over if it already matches the spec ('AdoptIfMatching').
+	// +kubebuilder:default=Adopt
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+	// RenamePolicy defines what happens when name or domain are changed after the user was applied: the change is
+	// rejected ('Reject'), or the user is created at the new address and deleted at the old one ('Recreate').
+	// +kubebuilder:default=Reject
+	RenamePolicy RenamePolicy `json:"renamePolicy,omitempty"`
+}
+
+// UserSettings are the mailbox settings of a user, which can be defaulted by a UserClass or for all users of a Domain.
+// Settings that are set on none of them are disabled, the quota is unlimited.
+type UserSettings struct {
+	// AllowSpoofing allows this user to send e-mails with any sender.
+	AllowSpoofing *bool `json:"allowSpoofing,omitempty"`
+	// EnableIMAP states if IMAP is available to the user.
+	EnableIMAP *bool `json:"enableIMAP,omitempty"`
+	// EnablePOP states if POP3 is available to the user.
+	EnablePOP *bool `json:"enablePOP,omitempty"`
+	// QuotaBytes defines the storage quota, -1 for unlimited.
+	QuotaBytes *int64 `json:"quotaBytes,omitempty"`
 	// SpamEnabled states if e-mail should be scanned for SPAM.
-	// +kubebuilder:default=false
-	SpamEnabled bool `json:"spamEnabled,omitempty"`
+	SpamEnabled *bool `json:"spamEnabled,omitempty"`
 	// SpamMarkAsRead states if identified SPAM e-mails should be marked as read.
-	// +kubebuilder:default=false
-	SpamMarkAsRead bool `json:"spamMarkAsRead,omitempty"`
+	SpamMarkAsRead *bool `json:"spamMarkAsRead,omitempty"`
 	// SpamThreshold is the threshold for the SPAM filter.
-	// +kubebuilder:default=0
-	SpamThreshold int `json:"spamThreshold,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SpamThreshold *int `json:"spamThreshold,omitempty"`
+}
+
+// UserExpiryAction defines what happens in MailU when a user expires.
+// +kubebuilder:validation:Enum=Disable;Delete
+type UserExpiryAction string
+
+const (
+	// UserExpiryActionDisable disables the user in MailU.
+	UserExpiryActionDisable UserExpiryAction = "Disable"
+	// UserExpiryActionDelete deletes the user in MailU.
+	UserExpiryActionDelete UserExpiryAction = "Delete"
+)
+
+// UserPhase is the lifecycle phase of a user.
+// +kubebuilder:validation:Enum=Pending;Active;Expiring;Expired
+type UserPhase string
+
+const (
+	// UserPhasePending is a user before its activeFrom time, it is disabled in MailU.
+	UserPhasePending UserPhase = "Pending"
+	// UserPhaseActive is a user between its activeFrom and activeUntil times.
+	UserPhaseActive UserPhase = "Active"
+	// UserPhaseExpiring is an active user that expires within a week.
+	UserPhaseExpiring UserPhase = "Expiring"
+	// UserPhaseExpired is a user after its activeUntil time, it is disabled or deleted in MailU.
+	UserPhaseExpired UserPhase = "Expired"
+)
+
+// UserAliasState is the state of an alias of a user in MailU.
+// +kubebuilder:validation:Enum=Applied;Conflict;Error
+type UserAliasState string
+
+const (
+	// UserAliasStateApplied is an alias created and managed by the user.
+	UserAliasStateApplied UserAliasState = "Applied"
+	// UserAliasStateConflict is an alias that exists in MailU, but is not managed by the user.
+	UserAliasStateConflict UserAliasState = "Conflict"
+	// UserAliasStateError is an alias that could not be applied.
+	UserAliasStateError UserAliasState = "Error"
+)
+
+// UserAliasStatus is the state of an alias of a user.
+type UserAliasStatus struct {
+	// Address of the alias.
+	Address string `json:"address"`
+	// State of the alias in MailU.
+	State UserAliasState `json:"state"`
+	// Message describes the state, if the alias is not applied.
+	Message string `json:"message,omitempty"`
 }
 
 // UserStatus defines the observed state of User
 type UserStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// ObservedGeneration is the generation of the spec that was last applied to MailU.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// AppliedAddress is the address of the user in MailU that was last applied.
+	AppliedAddress string `json:"appliedAddress,omitempty"`
+	// Aliases are the states of the aliases of the user in MailU.
+	Aliases []UserAliasStatus `json:"aliases,omitempty"`
+	// Settings are the effective mailbox settings applied to MailU, including the UserClass and the defaults of the Domain.
+	Settings *UserSettings `json:"settings,omitempty"`
+	// Phase is the lifecycle phase of the user that was last applied to MailU.
+	Phase UserPhase `json:"phase,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
 
 // User is the Schema for the users API
 type User struct {
@@ -0,0 +1,53 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UserClassSpec defines the mailbox settings of a class of users, e.g. staff, shared mailboxes or service accounts.
+type UserClassSpec struct {
+	// Description of the class of users.
+	Description string `json:"description,omitempty"`
+	// UserSettings of the users of this class, which do not set them themselves.
+	UserSettings `json:",inline"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// UserClass is the Schema for the userclasses API
+type UserClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec UserClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// UserClassList contains a list of UserClass
+type UserClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UserClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UserClass{}, &UserClassList{})
+}
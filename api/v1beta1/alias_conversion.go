@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+// ConvertTo converts this Alias to the Hub version (v1alpha1).
+func (src *Alias) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.Alias)
+	spec := src.Spec.DeepCopy()
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = v1alpha1.AliasSpec{
+		Name:            spec.Name,
+		Domain:          spec.Domain,
+		Comment:         spec.Comment,
+		Destination:     spec.Destination,
+		DestinationRefs: toDestinationRefs(spec.DestinationRefs),
+		Wildcard:        spec.Wildcard,
+		IgnoreFields:    spec.IgnoreFields,
+		DeletionPolicy:  v1alpha1.DeletionPolicy(spec.DeletionPolicy),
+		AdoptionPolicy:  v1alpha1.AdoptionPolicy(spec.AdoptionPolicy),
+		RenamePolicy:    v1alpha1.RenamePolicy(spec.RenamePolicy),
+	}
+	dst.Status = toAliasStatus(src.Status)
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+func (dst *Alias) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.Alias)
+	spec := src.Spec.DeepCopy()
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = AliasSpec{
+		Name:            spec.Name,
+		Domain:          spec.Domain,
+		Comment:         spec.Comment,
+		Destination:     spec.Destination,
+		DestinationRefs: fromDestinationRefs(spec.DestinationRefs),
+		Wildcard:        spec.Wildcard,
+		IgnoreFields:    spec.IgnoreFields,
+		DeletionPolicy:  DeletionPolicy(spec.DeletionPolicy),
+		AdoptionPolicy:  AdoptionPolicy(spec.AdoptionPolicy),
+		RenamePolicy:    RenamePolicy(spec.RenamePolicy),
+	}
+	dst.Status = fromAliasStatus(src.Status)
+
+	return nil
+}
+
+func toDestinationRefs(refs []DestinationRef) []v1alpha1.DestinationRef {
+	if refs == nil {
+		return nil
+	}
+	out := make([]v1alpha1.DestinationRef, 0, len(refs))
+	for _, ref := range refs {
+		out = append(out, v1alpha1.DestinationRef(ref))
+	}
+	return out
+}
+
+func fromDestinationRefs(refs []v1alpha1.DestinationRef) []DestinationRef {
+	if refs == nil {
+		return nil
+	}
+	out := make([]DestinationRef, 0, len(refs))
+	for _, ref := range refs {
+		out = append(out, DestinationRef(ref))
+	}
+	return out
+}
+
+func toAliasStatus(status AliasStatus) v1alpha1.AliasStatus {
+	s := status.DeepCopy()
+	out := v1alpha1.AliasStatus{
+		Conditions:           s.Conditions,
+		ObservedGeneration:   s.ObservedGeneration,
+		AppliedAddress:       s.AppliedAddress,
+		ResolvedDestinations: s.ResolvedDestinations,
+	}
+	for _, d := range s.Destinations {
+		out.Destinations = append(out.Destinations, v1alpha1.DestinationStatus{
+			Address: d.Address,
+			State:   v1alpha1.DestinationState(d.State),
+			Message: d.Message,
+		})
+	}
+	return out
+}
+
+func fromAliasStatus(status v1alpha1.AliasStatus) AliasStatus {
+	s := status.DeepCopy()
+	out := AliasStatus{
+		Conditions:           s.Conditions,
+		ObservedGeneration:   s.ObservedGeneration,
+		AppliedAddress:       s.AppliedAddress,
+		ResolvedDestinations: s.ResolvedDestinations,
+	}
+	for _, d := range s.Destinations {
+		out.Destinations = append(out.Destinations, DestinationStatus{
+			Address: d.Address,
+			State:   DestinationState(d.State),
+			Message: d.Message,
+		})
+	}
+	return out
+}
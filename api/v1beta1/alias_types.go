@@ -0,0 +1,129 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AliasSpec defines the desired state of Alias
+type AliasSpec struct {
+	// Name part of e-mail address 'name@domain'.
+	Name string `json:"name"`
+	// Domain part of e-mail address 'name@domain'.
+	Domain string `json:"domain"`
+	// Comment is a custom comment for the alias.
+	Comment string `json:"comment,omitempty"`
+	// Destination is a list of destinations for e-mails to 'name@domain'.
+	Destination []string `json:"destination,omitempty"`
+	// DestinationRefs are references to User and Alias resources whose addresses are added to the destinations.
+	DestinationRefs []DestinationRef `json:"destinationRefs,omitempty"`
+	// Wildcard must be set to 'true' if the name contains the wildcard character '%'.
+	// +kubebuilder:default=false
+	Wildcard bool `json:"wildcard,omitempty"`
+	// IgnoreFields lists fields which are only set on creation and not updated afterwards, so they can be
+	// changed in the Mailu UI, e.g. 'destination'.
+	IgnoreFields []string `json:"ignoreFields,omitempty"`
+	// DeletionPolicy defines if the alias is deleted in MailU ('Delete') or kept ('Retain') when this resource is deleted.
+	// Defaults to the deletion policy of the operator.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// AdoptionPolicy defines if an existing alias in MailU is taken over ('Adopt'), never taken over ('FailIfExists')
+	// or only taken over if it already matches the spec ('AdoptIfMatching').
+	// +kubebuilder:default=Adopt
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+	// RenamePolicy defines what happens when name or domain are changed after the alias was applied: the change is
+	// rejected ('Reject'), or the alias is created at the new address and deleted at the old one ('Recreate').
+	// +kubebuilder:default=Reject
+	RenamePolicy RenamePolicy `json:"renamePolicy,omitempty"`
+}
+
+// DestinationRef is a reference to a User or Alias resource as a destination of an alias.
+type DestinationRef struct {
+	// Kind of the referenced resource, 'User' or 'Alias'.
+	// +kubebuilder:validation:Enum=User;Alias
+	// +kubebuilder:default=User
+	Kind string `json:"kind,omitempty"`
+	// Name of the referenced resource.
+	Name string `json:"name"`
+	// Namespace of the referenced resource, defaults to the namespace of the alias. References to other namespaces
+	// must be allowed in the operator.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// DestinationState is the state of a destination of an alias in MailU.
+// +kubebuilder:validation:Enum=Exists;Missing;External;Unknown
+type DestinationState string
+
+const (
+	// DestinationStateExists is a user or alias in MailU.
+	DestinationStateExists DestinationState = "Exists"
+	// DestinationStateMissing is in a domain of MailU, but no user or alias.
+	DestinationStateMissing DestinationState = "Missing"
+	// DestinationStateExternal is in a domain unknown to MailU.
+	DestinationStateExternal DestinationState = "External"
+	// DestinationStateUnknown could not be validated.
+	DestinationStateUnknown DestinationState = "Unknown"
+)
+
+// DestinationStatus is the validated state of a destination of an alias.
+type DestinationStatus struct {
+	// Address of the destination.
+	Address string `json:"address"`
+	// State of the destination in MailU.
+	State DestinationState `json:"state"`
+	// Message describes the state, if the destination does not exist.
+	Message string `json:"message,omitempty"`
+}
+
+// AliasStatus defines the observed state of Alias
+type AliasStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// ObservedGeneration is the generation of the spec that was last applied to MailU.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// AppliedAddress is the address of the alias in MailU that was last applied.
+	AppliedAddress string `json:"appliedAddress,omitempty"`
+	// ResolvedDestinations are the addresses resolved from the destination references that were last applied.
+	ResolvedDestinations []string `json:"resolvedDestinations,omitempty"`
+	// Destinations are the destinations of the alias as validated in MailU after they were last applied.
+	Destinations []DestinationStatus `json:"destinations,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// Alias is the Schema for the aliases API
+type Alias struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AliasSpec   `json:"spec,omitempty"`
+	Status AliasStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AliasList contains a list of Alias
+type AliasList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Alias `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Alias{}, &AliasList{})
+}
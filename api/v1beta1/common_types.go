@@ -0,0 +1,63 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// DeletionPolicy defines what happens with the object in MailU when the resource is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the object in MailU.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the object in MailU, it is no longer managed by the operator.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// AdoptionPolicy defines how an object that already exists in MailU is handled when the resource is created.
+// +kubebuilder:validation:Enum=Adopt;FailIfExists;AdoptIfMatching
+type AdoptionPolicy string
+
+const (
+	// AdoptionPolicyAdopt takes over the existing object and updates it to match the spec.
+	AdoptionPolicyAdopt AdoptionPolicy = "Adopt"
+	// AdoptionPolicyFailIfExists never takes over an existing object.
+	AdoptionPolicyFailIfExists AdoptionPolicy = "FailIfExists"
+	// AdoptionPolicyAdoptIfMatching only takes over an existing object if it already matches the spec.
+	AdoptionPolicyAdoptIfMatching AdoptionPolicy = "AdoptIfMatching"
+)
+
+// RenamePolicy defines what happens when the address of an applied resource is changed.
+// +kubebuilder:validation:Enum=Reject;Recreate
+type RenamePolicy string
+
+const (
+	// RenamePolicyReject rejects the change and keeps the object at the applied address in MailU.
+	RenamePolicyReject RenamePolicy = "Reject"
+	// RenamePolicyRecreate creates the object at the new address and deletes the one at the applied address.
+	RenamePolicyRecreate RenamePolicy = "Recreate"
+)
+
+// DeletionMode defines how a Domain is deleted, while users or aliases of it still exist.
+// +kubebuilder:validation:Enum=Block;Cascade
+type DeletionMode string
+
+const (
+	// DeletionModeBlock keeps the Domain until all its users and aliases are deleted.
+	DeletionModeBlock DeletionMode = "Block"
+	// DeletionModeCascade deletes the User and Alias resources of the Domain first, then the Domain.
+	DeletionModeCascade DeletionMode = "Cascade"
+)
@@ -0,0 +1,175 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SpecAnnotation keeps the v1beta1 spec on the v1alpha1 hub, so values which v1alpha1 cannot represent
+// (unset flags, times, quantity formats, optional secrets) survive a conversion round trip.
+const SpecAnnotation = "operator.mailu.io/v1beta1-spec"
+
+// dateLayout is the format of dates in v1alpha1.
+const dateLayout = "2006-01-02"
+
+// storeSpec stores spec in the SpecAnnotation of meta.
+func storeSpec(meta *metav1.ObjectMeta, spec any) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[SpecAnnotation] = string(data)
+	return nil
+}
+
+// restoreSpec reads spec from the SpecAnnotation of meta and removes the annotation.
+// It returns false if there is no annotation.
+func restoreSpec(meta *metav1.ObjectMeta, spec any) (bool, error) {
+	data, ok := meta.Annotations[SpecAnnotation]
+	if !ok {
+		return false, nil
+	}
+	delete(meta.Annotations, SpecAnnotation)
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+	return true, json.Unmarshal([]byte(data), spec)
+}
+
+func fromBool(b *bool) bool {
+	return b != nil && *b
+}
+
+func toBool(b bool) *bool {
+	return &b
+}
+
+// restoreBool keeps an unset flag unset, as long as it was not enabled in v1alpha1.
+func restoreBool(prev, cur *bool) *bool {
+	if prev == nil && !fromBool(cur) {
+		return nil
+	}
+	return cur
+}
+
+// fromQuantity returns the value of q, or unset if q is nil.
+func fromQuantity(q *resource.Quantity, unset int64) int64 {
+	if q == nil {
+		return unset
+	}
+	return q.Value()
+}
+
+func toQuantity(v, unset int64) *resource.Quantity {
+	if v == unset {
+		return nil
+	}
+	return resource.NewQuantity(v, resource.BinarySI)
+}
+
+// fromOptionalQuantity returns the value of q, or nil if q is nil.
+func fromOptionalQuantity(q *resource.Quantity) *int64 {
+	if q == nil {
+		return nil
+	}
+	v := q.Value()
+	return &v
+}
+
+// toOptionalQuantity returns v as quantity, or nil if v is nil. Other than toQuantity, it keeps any value, as an
+// optional quantity is unset by nil.
+func toOptionalQuantity(v *int64) *resource.Quantity {
+	if v == nil {
+		return nil
+	}
+	return resource.NewQuantity(*v, resource.BinarySI)
+}
+
+// restoreQuantity keeps the previous quantity and its format, as long as its value did not change in v1alpha1.
+func restoreQuantity(prev, cur *resource.Quantity, unset int64) *resource.Quantity {
+	if prev != nil && fromQuantity(prev, unset) == fromQuantity(cur, unset) {
+		return prev
+	}
+	return cur
+}
+
+// restoreOptionalQuantity keeps the previous quantity and its format, as long as it is set to the same value in
+// v1alpha1.
+func restoreOptionalQuantity(prev, cur *resource.Quantity) *resource.Quantity {
+	if prev != nil && cur != nil && prev.Value() == cur.Value() {
+		return prev
+	}
+	return cur
+}
+
+// fromTime returns the date of t in UTC, or unset if t is nil.
+func fromTime(t *metav1.Time, unset string) string {
+	if t == nil {
+		return unset
+	}
+	return t.UTC().Format(dateLayout)
+}
+
+func toTime(date, unset string) (*metav1.Time, error) {
+	if date == "" || date == unset {
+		return nil, nil
+	}
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.Time{Time: t}, nil
+}
+
+// restoreTime keeps the previous time of day, as long as the date did not change in v1alpha1.
+func restoreTime(prev, cur *metav1.Time, unset string) *metav1.Time {
+	if prev != nil && fromTime(prev, unset) == fromTime(cur, unset) {
+		return prev
+	}
+	return cur
+}
+
+func fromSecretKeySelector(s *corev1.SecretKeySelector) (string, string) {
+	if s == nil {
+		return "", ""
+	}
+	return s.Name, s.Key
+}
+
+func toSecretKeySelector(name, key string) *corev1.SecretKeySelector {
+	if name == "" && key == "" {
+		return nil
+	}
+	return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: key}
+}
+
+// restoreSecretKeySelector keeps the previous selector, as long as name and key did not change in v1alpha1.
+func restoreSecretKeySelector(prev, cur *corev1.SecretKeySelector) *corev1.SecretKeySelector {
+	if prev != nil && cur != nil && prev.Name == cur.Name && prev.Key == cur.Key {
+		return prev
+	}
+	return cur
+}
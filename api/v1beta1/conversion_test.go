@@ -0,0 +1,196 @@
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	"github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func TestIsConvertible(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range []runtime.Object{&Domain{}, &User{}, &Alias{}} {
+		ok, err := conversion.IsConvertible(scheme, obj)
+		if err != nil || !ok {
+			t.Errorf("IsConvertible(%T) = %v, %v", obj, ok, err)
+		}
+	}
+}
+
+func TestUserConversion(t *testing.T) {
+	quota := resource.MustParse("5Gi")
+	quotaBytes := int64(5 * 1024 * 1024 * 1024)
+	start := metav1.NewTime(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	user := &User{
+		ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "default", Annotations: map[string]string{"a": "b"}},
+		Spec: UserSpec{
+			Name:              "john.doe",
+			Domain:            "example.com",
+			Enabled:           toBool(true),
+			EnableIMAP:        toBool(false),
+			PasswordSecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "passwords"}, Key: "john.doe", Optional: toBool(true)},
+			Quota:             &quota,
+			RawPassword:       "s3cr3t!",
+			ReplyStartTime:    &start,
+			Aliases:           []string{"first.last", "info@example.org"},
+			UserClassName:     "staff",
+			ActiveUntil:       &start,
+			ExpiryAction:      UserExpiryActionDelete,
+		},
+		Status: UserStatus{ObservedGeneration: 2, AppliedAddress: "john.doe@example.com",
+			Aliases:  []UserAliasStatus{{Address: "first.last@example.com", State: UserAliasStateApplied}},
+			Settings: &UserSettings{EnableIMAP: toBool(false), Quota: &quota}, Phase: UserPhaseExpired},
+	}
+
+	hub := &v1alpha1.User{}
+	if err := user.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	want := v1alpha1.UserSpec{
+		Name:           "john.doe",
+		Domain:         "example.com",
+		UserSettings:   v1alpha1.UserSettings{EnableIMAP: toBool(false), QuotaBytes: &quotaBytes},
+		Enabled:        true,
+		PasswordSecret: "passwords",
+		PasswordKey:    "john.doe",
+		RawPassword:    "s3cr3t!",
+		ReplyStartDate: "2024-01-01",
+		ReplyEndDate:   "2999-12-31",
+		Aliases:        []string{"first.last", "info@example.org"},
+		UserClassName:  "staff",
+		ActiveUntil:    &start,
+		ExpiryAction:   v1alpha1.UserExpiryActionDelete,
+	}
+	if !equality.Semantic.DeepEqual(hub.Spec, want) {
+		t.Errorf("ConvertTo() spec = %+v, want %+v", hub.Spec, want)
+	}
+	if hub.Status.AppliedAddress != user.Status.AppliedAddress {
+		t.Errorf("ConvertTo() status = %+v", hub.Status)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		got := &User{}
+		if err := got.ConvertFrom(hub.DeepCopy()); err != nil {
+			t.Fatal(err)
+		}
+		if !equality.Semantic.DeepEqual(got, user) {
+			t.Errorf("ConvertFrom() = %+v, want %+v", got, user)
+		}
+	})
+
+	t.Run("changed in v1alpha1", func(t *testing.T) {
+		changed := hub.DeepCopy()
+		changed.Spec.EnablePOP = toBool(true)
+		changedQuota := int64(1000)
+		changed.Spec.QuotaBytes = &changedQuota
+		changed.Spec.ReplyStartDate = "2024-02-01"
+
+		got := &User{}
+		if err := got.ConvertFrom(changed); err != nil {
+			t.Fatal(err)
+		}
+		if !fromBool(got.Spec.EnablePOP) || got.Spec.Quota.Value() != 1000 || fromTime(got.Spec.ReplyStartTime, "") != "2024-02-01" {
+			t.Errorf("ConvertFrom() spec = %+v", got.Spec)
+		}
+		if got.Spec.AllowSpoofing != nil || got.Spec.EnableIMAP == nil || got.Spec.PasswordSecretRef.Optional == nil {
+			t.Errorf("ConvertFrom() did not restore unchanged fields: %+v", got.Spec)
+		}
+	})
+
+	t.Run("from v1alpha1", func(t *testing.T) {
+		got := &User{}
+		if err := got.ConvertFrom(&v1alpha1.User{Spec: want}); err != nil {
+			t.Fatal(err)
+		}
+		if got.Spec.AllowSpoofing != nil || got.Spec.Quota.String() != "5Gi" || got.Spec.ReplyEndTime != nil {
+			t.Errorf("ConvertFrom() spec = %+v", got.Spec)
+		}
+	})
+
+	t.Run("unlimited quota", func(t *testing.T) {
+		unlimited := int64(-1)
+		alpha := &v1alpha1.User{Spec: want}
+		alpha.Spec.QuotaBytes = &unlimited
+		alpha.Status.Settings = &v1alpha1.UserSettings{QuotaBytes: &unlimited}
+
+		got := &User{}
+		if err := got.ConvertFrom(alpha.DeepCopy()); err != nil {
+			t.Fatal(err)
+		}
+		if got.Spec.Quota == nil || got.Spec.Quota.Value() != -1 || got.Status.Settings.Quota == nil {
+			t.Fatalf("ConvertFrom() quota = %v, want -1", got.Spec.Quota)
+		}
+
+		back := &v1alpha1.User{}
+		if err := got.ConvertTo(back); err != nil {
+			t.Fatal(err)
+		}
+		if back.Spec.QuotaBytes == nil || *back.Spec.QuotaBytes != -1 {
+			t.Errorf("ConvertTo() quotaBytes = %v, want -1", back.Spec.QuotaBytes)
+		}
+		if back.Status.Settings.QuotaBytes == nil || *back.Status.Settings.QuotaBytes != -1 {
+			t.Errorf("ConvertTo() status quotaBytes = %v, want -1", back.Status.Settings.QuotaBytes)
+		}
+	})
+}
+
+func TestDomainConversion(t *testing.T) {
+	quota := resource.MustParse("0")
+	domain := &Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "domain", Namespace: "default"},
+		Spec: DomainSpec{Name: "example.com", MaxUsers: -1, MaxAliases: 10, MaxQuota: &quota, DeletionMode: DeletionModeCascade,
+			RoleAliases: map[string][]string{"postmaster": {"admin@example.com"}}, CatchAll: []string{"admin@example.com"},
+			UserDefaults: &UserSettings{SpamEnabled: toBool(true), Quota: &quota}},
+		Status: DomainStatus{Aliases: []DomainAliasStatus{{Address: "postmaster@example.com", State: UserAliasStateApplied}}},
+	}
+
+	hub := &v1alpha1.Domain{}
+	if err := domain.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Spec.MaxQuotaBytes != 0 || hub.Spec.SignupEnabled || hub.Spec.MaxAliases != 10 {
+		t.Errorf("ConvertTo() spec = %+v", hub.Spec)
+	}
+
+	got := &Domain{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(got, domain) {
+		t.Errorf("ConvertFrom() = %+v, want %+v", got, domain)
+	}
+}
+
+func TestAliasConversion(t *testing.T) {
+	alias := &Alias{
+		ObjectMeta: metav1.ObjectMeta{Name: "alias", Namespace: "default"},
+		Spec: AliasSpec{Name: "info", Domain: "example.com", Destination: []string{"john.doe@example.com"},
+			DestinationRefs: []DestinationRef{{Kind: "User", Name: "jane", Namespace: "other"}}},
+		Status: AliasStatus{Destinations: []DestinationStatus{{Address: "john.doe@example.com", State: DestinationStateExists}}},
+	}
+
+	hub := &v1alpha1.Alias{}
+	if err := alias.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	got := &Alias{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(got, alias) {
+		t.Errorf("ConvertFrom() = %+v, want %+v", got, alias)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+// ConvertTo converts this Domain to the Hub version (v1alpha1).
+func (src *Domain) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.Domain)
+	spec := src.Spec.DeepCopy()
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = v1alpha1.DomainSpec{
+		Name:           spec.Name,
+		Comment:        spec.Comment,
+		MaxUsers:       spec.MaxUsers,
+		MaxAliases:     spec.MaxAliases,
+		MaxQuotaBytes:  int(fromQuantity(spec.MaxQuota, 0)),
+		SignupEnabled:  fromBool(spec.SignupEnabled),
+		Alternatives:   spec.Alternatives,
+		IgnoreFields:   spec.IgnoreFields,
+		DeletionPolicy: v1alpha1.DeletionPolicy(spec.DeletionPolicy),
+		AdoptionPolicy: v1alpha1.AdoptionPolicy(spec.AdoptionPolicy),
+		DeletionMode:   v1alpha1.DeletionMode(spec.DeletionMode),
+		RoleAliases:    spec.RoleAliases,
+		CatchAll:       spec.CatchAll,
+		UserDefaults:   toUserSettings(spec.UserDefaults),
+	}
+	dst.Status = toDomainStatus(src.Status)
+
+	return storeSpec(&dst.ObjectMeta, spec)
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+func (dst *Domain) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.Domain)
+	spec := src.Spec.DeepCopy()
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = DomainSpec{
+		Name:           spec.Name,
+		Comment:        spec.Comment,
+		MaxUsers:       spec.MaxUsers,
+		MaxAliases:     spec.MaxAliases,
+		MaxQuota:       toQuantity(int64(spec.MaxQuotaBytes), 0),
+		SignupEnabled:  toBool(spec.SignupEnabled),
+		Alternatives:   spec.Alternatives,
+		IgnoreFields:   spec.IgnoreFields,
+		DeletionPolicy: DeletionPolicy(spec.DeletionPolicy),
+		AdoptionPolicy: AdoptionPolicy(spec.AdoptionPolicy),
+		DeletionMode:   DeletionMode(spec.DeletionMode),
+		RoleAliases:    spec.RoleAliases,
+		CatchAll:       spec.CatchAll,
+		UserDefaults:   fromUserSettings(spec.UserDefaults),
+	}
+	dst.Status = fromDomainStatus(src.Status)
+
+	var prev DomainSpec
+	ok, err := restoreSpec(&dst.ObjectMeta, &prev)
+	if err != nil || !ok {
+		return err
+	}
+	dst.Spec.MaxQuota = restoreQuantity(prev.MaxQuota, dst.Spec.MaxQuota, 0)
+	dst.Spec.SignupEnabled = restoreBool(prev.SignupEnabled, dst.Spec.SignupEnabled)
+
+	return nil
+}
+
+func toDomainStatus(status DomainStatus) v1alpha1.DomainStatus {
+	s := status.DeepCopy()
+	out := v1alpha1.DomainStatus{
+		Conditions:         s.Conditions,
+		ObservedGeneration: s.ObservedGeneration,
+	}
+	for _, a := range s.Aliases {
+		out.Aliases = append(out.Aliases, v1alpha1.DomainAliasStatus{
+			Address: a.Address,
+			State:   v1alpha1.UserAliasState(a.State),
+			Message: a.Message,
+		})
+	}
+	return out
+}
+
+func fromDomainStatus(status v1alpha1.DomainStatus) DomainStatus {
+	s := status.DeepCopy()
+	out := DomainStatus{
+		Conditions:         s.Conditions,
+		ObservedGeneration: s.ObservedGeneration,
+	}
+	for _, a := range s.Aliases {
+		out.Aliases = append(out.Aliases, DomainAliasStatus{
+			Address: a.Address,
+			State:   UserAliasState(a.State),
+			Message: a.Message,
+		})
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DomainSpec defines the desired state of Domain
+type DomainSpec struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// Domain name.
+	Name string `json:"name"`
+	// Comment is a custom comment for the domain.
+	Comment string `json:"comment,omitempty"`
+	// MaxUsers, default -1 for unlimited.
+	// +kubebuilder:default=-1
+	MaxUsers int `json:"maxUsers,omitempty"`
+	// MaxAliases, default -1 for unlimited.
+	// +kubebuilder:default=-1
+	MaxAliases int `json:"maxAliases,omitempty"`
+	// MaxQuota is the maximum storage quota of a user in this domain, e.g. '5Gi'. Unlimited if not set.
+	MaxQuota *resource.Quantity `json:"maxQuota,omitempty"`
+	// SignupEnabled allows users to self-signup for this domain.
+	SignupEnabled *bool `json:"signupEnabled,omitempty"`
+	// Alternatives contains alternative domain names.
+	Alternatives []string `json:"alternatives,omitempty"`
+	// IgnoreFields lists fields which are only set on creation and not updated afterwards, so they can be
+	// changed in the Mailu UI. Wildcards are supported, e.g. 'max*'.
+	IgnoreFields []string `json:"ignoreFields,omitempty"`
+	// DeletionPolicy defines if the domain is deleted in MailU ('Delete') or kept ('Retain') when this resource is deleted.
+	// Defaults to the deletion policy of the operator.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// AdoptionPolicy defines if an existing domain in MailU is taken over ('Adopt'), never taken over ('FailIfExists')
+	// or only taken over if it already matches the spec ('AdoptIfMatching').
+	// +kubebuilder:default=Adopt
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+	// DeletionMode defines what happens when the domain is deleted in MailU while it still has users or aliases:
+	// the deletion waits until they are deleted ('Block'), or their resources in the namespace of the domain are
+	// deleted first ('Cascade'). Resources in other namespaces always block the deletion.
+	// +kubebuilder:default=Block
+	DeletionMode DeletionMode `json:"deletionMode,omitempty"`
+	// RoleAliases maps role names of the domain, e.g. 'postmaster' or 'abuse' (RFC 2142), to their destinations.
+	// Each role is created as an alias of the domain in MailU and kept in sync.
+	RoleAliases map[string][]string `json:"roleAliases,omitempty"`
+	// CatchAll lists the destinations of the catch-all alias '%@domain', which receives the emails to all addresses
+	// of the domain without a user or alias.
+	CatchAll []string `json:"catchAll,omitempty"`
+	// UserDefaults are the mailbox settings of all users of the domain which do not set them.
+	UserDefaults *UserSettings `json:"userDefaults,omitempty"`
+}
+
+// DomainAliasStatus is the state of a role or catch-all alias of a domain.
+type DomainAliasStatus struct {
+	// Address of the alias.
+	Address string `json:"address"`
+	// State of the alias in MailU.
+	State UserAliasState `json:"state"`
+	// Message describes the state, if the alias is not applied.
+	Message string `json:"message,omitempty"`
+}
+
+// DomainStatus defines the observed state of Domain
+type DomainStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// ObservedGeneration is the generation of the spec that was last applied to MailU.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Aliases are the role and catch-all aliases of the domain.
+	Aliases []DomainAliasStatus `json:"aliases,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// Domain is the Schema for the domains API
+type Domain struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DomainSpec   `json:"spec,omitempty"`
+	Status DomainStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DomainList contains a list of Domain
+type DomainList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Domain `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Domain{}, &DomainList{})
+}
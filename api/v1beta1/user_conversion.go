@@ -0,0 +1,222 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+const (
+	// unsetReplyStartDate and unsetReplyEndDate are the v1alpha1 defaults for an unrestricted auto-reply.
+	unsetReplyStartDate = "1900-01-01"
+	unsetReplyEndDate   = "2999-12-31"
+)
+
+// ConvertTo converts this User to the Hub version (v1alpha1).
+func (src *User) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.User)
+	spec := src.Spec.DeepCopy()
+
+	passwordSecret, passwordKey := fromSecretKeySelector(spec.PasswordSecretRef)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = v1alpha1.UserSpec{
+		Name:   spec.Name,
+		Domain: spec.Domain,
+		UserSettings: v1alpha1.UserSettings{
+			AllowSpoofing:  spec.AllowSpoofing,
+			EnableIMAP:     spec.EnableIMAP,
+			EnablePOP:      spec.EnablePOP,
+			QuotaBytes:     fromOptionalQuantity(spec.Quota),
+			SpamEnabled:    spec.SpamEnabled,
+			SpamMarkAsRead: spec.SpamMarkAsRead,
+			SpamThreshold:  spec.SpamThreshold,
+		},
+		ChangePassword:     fromBool(spec.ChangePassword),
+		Comment:            spec.Comment,
+		DisplayedName:      spec.DisplayedName,
+		Enabled:            fromBool(spec.Enabled),
+		ForwardEnabled:     fromBool(spec.ForwardEnabled),
+		ForwardDestination: spec.ForwardDestination,
+		ForwardKeep:        fromBool(spec.ForwardKeep),
+		GlobalAdmin:        fromBool(spec.GlobalAdmin),
+		PasswordSecret:     passwordSecret,
+		PasswordKey:        passwordKey,
+		RawPassword:        spec.RawPassword,
+		ReplyEnabled:       fromBool(spec.ReplyEnabled),
+		ReplySubject:       spec.ReplySubject,
+		ReplyBody:          spec.ReplyBody,
+		ReplyStartDate:     fromTime(spec.ReplyStartTime, unsetReplyStartDate),
+		ReplyEndDate:       fromTime(spec.ReplyEndTime, unsetReplyEndDate),
+		Aliases:            spec.Aliases,
+		UserClassName:      spec.UserClassName,
+		ActiveFrom:         spec.ActiveFrom,
+		ActiveUntil:        spec.ActiveUntil,
+		ExpiryAction:       v1alpha1.UserExpiryAction(spec.ExpiryAction),
+		IgnoreFields:       spec.IgnoreFields,
+		DeletionPolicy:     v1alpha1.DeletionPolicy(spec.DeletionPolicy),
+		AdoptionPolicy:     v1alpha1.AdoptionPolicy(spec.AdoptionPolicy),
+		RenamePolicy:       v1alpha1.RenamePolicy(spec.RenamePolicy),
+	}
+	dst.Status = toUserStatus(src.Status)
+
+	// the password is in the spec already, do not copy it into an annotation
+	spec.RawPassword = ""
+	return storeSpec(&dst.ObjectMeta, spec)
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+func (dst *User) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.User)
+	spec := src.Spec.DeepCopy()
+
+	replyStartTime, err := toTime(spec.ReplyStartDate, unsetReplyStartDate)
+	if err != nil {
+		return err
+	}
+	replyEndTime, err := toTime(spec.ReplyEndDate, unsetReplyEndDate)
+	if err != nil {
+		return err
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = UserSpec{
+		Name:               spec.Name,
+		Domain:             spec.Domain,
+		AllowSpoofing:      spec.AllowSpoofing,
+		ChangePassword:     toBool(spec.ChangePassword),
+		Comment:            spec.Comment,
+		DisplayedName:      spec.DisplayedName,
+		Enabled:            toBool(spec.Enabled),
+		EnableIMAP:         spec.EnableIMAP,
+		EnablePOP:          spec.EnablePOP,
+		ForwardEnabled:     toBool(spec.ForwardEnabled),
+		ForwardDestination: spec.ForwardDestination,
+		ForwardKeep:        toBool(spec.ForwardKeep),
+		GlobalAdmin:        toBool(spec.GlobalAdmin),
+		PasswordSecretRef:  toSecretKeySelector(spec.PasswordSecret, spec.PasswordKey),
+		Quota:              toOptionalQuantity(spec.QuotaBytes),
+		RawPassword:        spec.RawPassword,
+		ReplyEnabled:       toBool(spec.ReplyEnabled),
+		ReplySubject:       spec.ReplySubject,
+		ReplyBody:          spec.ReplyBody,
+		ReplyStartTime:     replyStartTime,
+		ReplyEndTime:       replyEndTime,
+		SpamEnabled:        spec.SpamEnabled,
+		SpamMarkAsRead:     spec.SpamMarkAsRead,
+		SpamThreshold:      spec.SpamThreshold,
+		Aliases:            spec.Aliases,
+		UserClassName:      spec.UserClassName,
+		ActiveFrom:         spec.ActiveFrom,
+		ActiveUntil:        spec.ActiveUntil,
+		ExpiryAction:       UserExpiryAction(spec.ExpiryAction),
+		IgnoreFields:       spec.IgnoreFields,
+		DeletionPolicy:     DeletionPolicy(spec.DeletionPolicy),
+		AdoptionPolicy:     AdoptionPolicy(spec.AdoptionPolicy),
+		RenamePolicy:       RenamePolicy(spec.RenamePolicy),
+	}
+	dst.Status = fromUserStatus(src.Status)
+
+	var prev UserSpec
+	ok, err := restoreSpec(&dst.ObjectMeta, &prev)
+	if err != nil || !ok {
+		return err
+	}
+	dst.Spec.ChangePassword = restoreBool(prev.ChangePassword, dst.Spec.ChangePassword)
+	dst.Spec.Enabled = restoreBool(prev.Enabled, dst.Spec.Enabled)
+	dst.Spec.ForwardEnabled = restoreBool(prev.ForwardEnabled, dst.Spec.ForwardEnabled)
+	dst.Spec.ForwardKeep = restoreBool(prev.ForwardKeep, dst.Spec.ForwardKeep)
+	dst.Spec.GlobalAdmin = restoreBool(prev.GlobalAdmin, dst.Spec.GlobalAdmin)
+	dst.Spec.ReplyEnabled = restoreBool(prev.ReplyEnabled, dst.Spec.ReplyEnabled)
+	dst.Spec.PasswordSecretRef = restoreSecretKeySelector(prev.PasswordSecretRef, dst.Spec.PasswordSecretRef)
+	dst.Spec.Quota = restoreOptionalQuantity(prev.Quota, dst.Spec.Quota)
+	dst.Spec.ReplyStartTime = restoreTime(prev.ReplyStartTime, dst.Spec.ReplyStartTime, unsetReplyStartDate)
+	dst.Spec.ReplyEndTime = restoreTime(prev.ReplyEndTime, dst.Spec.ReplyEndTime, unsetReplyEndDate)
+
+	return nil
+}
+
+func toUserStatus(status UserStatus) v1alpha1.UserStatus {
+	s := status.DeepCopy()
+	out := v1alpha1.UserStatus{
+		Conditions:         s.Conditions,
+		ObservedGeneration: s.ObservedGeneration,
+		AppliedAddress:     s.AppliedAddress,
+		Settings:           toUserSettings(s.Settings),
+		Phase:              v1alpha1.UserPhase(s.Phase),
+	}
+	for _, a := range s.Aliases {
+		out.Aliases = append(out.Aliases, v1alpha1.UserAliasStatus{
+			Address: a.Address,
+			State:   v1alpha1.UserAliasState(a.State),
+			Message: a.Message,
+		})
+	}
+	return out
+}
+
+func fromUserStatus(status v1alpha1.UserStatus) UserStatus {
+	s := status.DeepCopy()
+	out := UserStatus{
+		Conditions:         s.Conditions,
+		ObservedGeneration: s.ObservedGeneration,
+		AppliedAddress:     s.AppliedAddress,
+		Settings:           fromUserSettings(s.Settings),
+		Phase:              UserPhase(s.Phase),
+	}
+	for _, a := range s.Aliases {
+		out.Aliases = append(out.Aliases, UserAliasStatus{
+			Address: a.Address,
+			State:   UserAliasState(a.State),
+			Message: a.Message,
+		})
+	}
+	return out
+}
+
+func toUserSettings(settings *UserSettings) *v1alpha1.UserSettings {
+	if settings == nil {
+		return nil
+	}
+	s := settings.DeepCopy()
+	return &v1alpha1.UserSettings{
+		AllowSpoofing:  s.AllowSpoofing,
+		EnableIMAP:     s.EnableIMAP,
+		EnablePOP:      s.EnablePOP,
+		QuotaBytes:     fromOptionalQuantity(s.Quota),
+		SpamEnabled:    s.SpamEnabled,
+		SpamMarkAsRead: s.SpamMarkAsRead,
+		SpamThreshold:  s.SpamThreshold,
+	}
+}
+
+func fromUserSettings(settings *v1alpha1.UserSettings) *UserSettings {
+	if settings == nil {
+		return nil
+	}
+	s := settings.DeepCopy()
+	return &UserSettings{
+		AllowSpoofing:  s.AllowSpoofing,
+		EnableIMAP:     s.EnableIMAP,
+		EnablePOP:      s.EnablePOP,
+		Quota:          toOptionalQuantity(s.QuotaBytes),
+		SpamEnabled:    s.SpamEnabled,
+		SpamMarkAsRead: s.SpamMarkAsRead,
+		SpamThreshold:  s.SpamThreshold,
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UserSpec defines the desired state of User
+type UserSpec struct {
+	// Name part of e-mail address 'name@domain'.
+	Name string `json:"name"`
+	// Domain part of e-mail address 'name@domain'.
+	Domain string `json:"domain"`
+	// AllowSpoofing allows this user to send e-mails with any sender.
+	AllowSpoofing *bool `json:"allowSpoofing,omitempty"`
+	// ChangePassword requires the user to change the password on next login.
+	ChangePassword *bool `json:"changePassword,omitempty"`
+	// Comment is a custom comment for the user.
+	Comment string `json:"comment,omitempty"`
+	// DisplayName is the name displayed for this user.
+	DisplayedName string `json:"displayedName,omitempty"`
+	// Enabled states the status of this user account.
+	Enabled *bool `json:"enabled,omitempty"`
+	// EnableIMAP states if IMAP is available to the user.
+	EnableIMAP *bool `json:"enableIMAP,omitempty"`
+	// EnablePOP states if POP3 is available to the user.
+	EnablePOP *bool `json:"enablePOP,omitempty"`
+	// ForwardEnabled states if e-mails are forwarded.
+	ForwardEnabled *bool `json:"forwardEnabled,omitempty"`
+	// ForwardDestination states the destination(s) to forward e-mail to.
+	ForwardDestination []string `json:"forwardDestination,omitempty"`
+	// ForwardKeep states if forwarded e-mail should be kept in the mailbox.
+	ForwardKeep *bool `json:"forwardKeep,omitempty"`
+	// GlobalAdmin states if the user has global admin privileges.
+	GlobalAdmin *bool `json:"globalAdmin,omitempty"`
+	// PasswordSecretRef selects the key of a secret in the namespace of the user which contains the password.
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// Quota is the storage quota, e.g. '5Gi', or '-1' for unlimited. Unlimited if set neither on the user, nor its
+	// UserClass or the userDefaults of the Domain.
+	Quota *resource.Quantity `json:"quota,omitempty"`
+	// RawPassword is the plaintext password for user creation.
+	RawPassword string `json:"rawPassword,omitempty"`
+	// ReplyEnabled states if e-mails should be auto-replied to.
+	ReplyEnabled *bool `json:"replyEnabled,omitempty"`
+	// ReplySubject is the subject for auto-reply e-mails.
+	ReplySubject string `json:"replySubject,omitempty"`
+	// ReplyBody is the body for auto-reply e-mails.
+	ReplyBody string `json:"replyBody,omitempty"`
+	// ReplyStartTime is the time from which on auto-reply e-mails should be sent. MailU only uses the date (UTC).
+	ReplyStartTime *metav1.Time `json:"replyStartTime,omitempty"`
+	// ReplyEndTime is the time until which auto-reply e-mails should be sent. MailU only uses the date (UTC).
+	ReplyEndTime *metav1.Time `json:"replyEndTime,omitempty"`
+	// SpamEnabled states if e-mail should be scanned for SPAM.
+	SpamEnabled *bool `json:"spamEnabled,omitempty"`
+	// SpamMarkAsRead states if identified SPAM e-mails should be marked as read.
+	SpamMarkAsRead *bool `json:"spamMarkAsRead,omitempty"`
+	// SpamThreshold is the threshold for the SPAM filter.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SpamThreshold *int `json:"spamThreshold,omitempty"`
+	// Aliases are additional addresses of the user, created as aliases in MailU with the user as destination.
+	// Entries without a domain are in the domain of the user, e.g. 'firstname.lastname'.
+	Aliases []string `json:"aliases,omitempty"`
+	// UserClassName is the name of a UserClass, whose settings apply to the settings not set on the user. They take
+	// precedence over the userDefaults of the Domain.
+	UserClassName string `json:"userClassName,omitempty"`
+	// ActiveFrom is the time from which on the user is enabled in MailU. Before, it is disabled.
+	ActiveFrom *metav1.Time `json:"activeFrom,omitempty"`
+	// ActiveUntil is the time at which the user expires and the expiryAction applies.
+	ActiveUntil *metav1.Time `json:"activeUntil,omitempty"`
+	// ExpiryAction defines if the user is disabled ('Disable', the default) or deleted ('Delete') in MailU once it
+	// expired. The resource itself is kept.
+	ExpiryAction UserExpiryAction `json:"expiryAction,omitempty"`
+	// IgnoreFields lists fields which are only set on creation and not updated afterwards, so they can be
+	// changed in the Mailu UI. Wildcards are supported, e.g. 'reply*' or 'forward*'.
+	IgnoreFields []string `json:"ignoreFields,omitempty"`
+	// DeletionPolicy defines if the user is deleted in MailU ('Delete') or kept ('Retain') when this resource is deleted.
+	// Defaults to the deletion policy of the operator.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// AdoptionPolicy defines if an existing user in MailU is taken over ('Adopt'), never taken over ('FailIfExists')
+	// or only taken over if it already matches the spec ('AdoptIfMatching').
+	// +kubebuilder:default=Adopt
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+	// RenamePolicy defines what happens when name or domain are changed after the user was applied: the change is
+	// rejected ('Reject'), or the user is created at the new address and deleted at the old one ('Recreate').
+	// +kubebuilder:default=Reject
+	RenamePolicy RenamePolicy `json:"renamePolicy,omitempty"`
+}
+
+// UserSettings are the mailbox settings of a user, which can be defaulted by a UserClass or for all users of a Domain.
+// Settings that are set on none of them are disabled, the quota is unlimited.
+type UserSettings struct {
+	// AllowSpoofing allows this user to send e-mails with any sender.
+	AllowSpoofing *bool `json:"allowSpoofing,omitempty"`
+	// EnableIMAP states if IMAP is available to the user.
+	EnableIMAP *bool `json:"enableIMAP,omitempty"`
+	// EnablePOP states if POP3 is available to the user.
+	EnablePOP *bool `json:"enablePOP,omitempty"`
+	// Quota is the storage quota, e.g. '5Gi', or '-1' for unlimited.
+	Quota *resource.Quantity `json:"quota,omitempty"`
+	// SpamEnabled states if e-mail should be scanned for SPAM.
+	SpamEnabled *bool `json:"spamEnabled,omitempty"`
+	// SpamMarkAsRead states if identified SPAM e-mails should be marked as read.
+	SpamMarkAsRead *bool `json:"spamMarkAsRead,omitempty"`
+	// SpamThreshold is the threshold for the SPAM filter.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SpamThreshold *int `json:"spamThreshold,omitempty"`
+}
+
+// UserExpiryAction defines what happens in MailU when a user expires.
+// +kubebuilder:validation:Enum=Disable;Delete
+type UserExpiryAction string
+
+const (
+	// UserExpiryActionDisable disables the user in MailU.
+	UserExpiryActionDisable UserExpiryAction = "Disable"
+	// UserExpiryActionDelete deletes the user in MailU.
+	UserExpiryActionDelete UserExpiryAction = "Delete"
+)
+
+// UserPhase is the lifecycle phase of a user.
+// +kubebuilder:validation:Enum=Pending;Active;Expiring;Expired
+type UserPhase string
+
+const (
+	// UserPhasePending is a user before its activeFrom time, it is disabled in MailU.
+	UserPhasePending UserPhase = "Pending"
+	// UserPhaseActive is a user between its activeFrom and activeUntil times.
+	UserPhaseActive UserPhase = "Active"
+	// UserPhaseExpiring is an active user that expires within a week.
+	UserPhaseExpiring UserPhase = "Expiring"
+	// UserPhaseExpired is a user after its activeUntil time, it is disabled or deleted in MailU.
+	UserPhaseExpired UserPhase = "Expired"
+)
+
+// UserAliasState is the state of an alias of a user in MailU.
+// +kubebuilder:validation:Enum=Applied;Conflict;Error
+type UserAliasState string
+
+const (
+	// UserAliasStateApplied is an alias created and managed by the user.
+	UserAliasStateApplied UserAliasState = "Applied"
+	// UserAliasStateConflict is an alias that exists in MailU, but is not managed by the user.
+	UserAliasStateConflict UserAliasState = "Conflict"
+	// UserAliasStateError is an alias that could not be applied.
+	UserAliasStateError UserAliasState = "Error"
+)
+
+// UserAliasStatus is the state of an alias of a user.
+type UserAliasStatus struct {
+	// Address of the alias.
+	Address string `json:"address"`
+	// State of the alias in MailU.
+	State UserAliasState `json:"state"`
+	// Message describes the state, if the alias is not applied.
+	Message string `json:"message,omitempty"`
+}
+
+// UserStatus defines the observed state of User
+type UserStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// ObservedGeneration is the generation of the spec that was last applied to MailU.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// AppliedAddress is the address of the user in MailU that was last applied.
+	AppliedAddress string `json:"appliedAddress,omitempty"`
+	// Aliases are the states of the aliases of the user in MailU.
+	Aliases []UserAliasStatus `json:"aliases,omitempty"`
+	// Settings are the effective mailbox settings applied to MailU, including the UserClass and the defaults of the Domain.
+	Settings *UserSettings `json:"settings,omitempty"`
+	// Phase is the lifecycle phase of the user that was last applied to MailU.
+	Phase UserPhase `json:"phase,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// User is the Schema for the users API
+type User struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UserSpec   `json:"spec,omitempty"`
+	Status UserStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// UserList contains a list of User
+type UserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []User `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&User{}, &UserList{})
+}
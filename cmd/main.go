@@ -17,10 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -40,7 +43,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	operatorv1beta1 "github.com/sickhub/mailu-operator/api/v1beta1"
 	"github.com/sickhub/mailu-operator/internal/controller"
+	webhookv1alpha1 "github.com/sickhub/mailu-operator/internal/webhook/v1alpha1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -53,6 +58,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(operatorv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(operatorv1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -64,6 +70,16 @@ func main() {
 	var enableHTTP2 bool
 	var mailuServer string
 	var mailuToken string
+	var resyncInterval time.Duration
+	var driftPolicy string
+	var observeOnly bool
+	var deletionPolicy string
+	var pruneInterval time.Duration
+	var pruneDelete bool
+	var pruneSelector string
+	var allowCrossNamespaceRefs bool
+	var restrictPrivilegedUsers bool
+	var privilegedNamespaces string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -75,6 +91,31 @@ func main() {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.StringVar(&mailuServer, "mailu-server", "http://mailu-front:80/api/v1/", "Mailu API server address")
 	flag.StringVar(&mailuToken, "mailu-token", "", "Mailu API token")
+	flag.DurationVar(&resyncInterval, "resync-interval", 0,
+		"Interval in which resources are compared with Mailu to detect drift, 0 disables the periodic resync. "+
+			"Can be overwritten per resource with the annotation "+controller.AnnotationResyncInterval+".")
+	flag.StringVar(&driftPolicy, "drift-policy", controller.DriftPolicyCorrect,
+		"Policy for resources changed in Mailu outside the operator: "+controller.DriftPolicyCorrect+" or "+
+			controller.DriftPolicyReport+". Can be overwritten per resource with the annotation "+controller.AnnotationDriftPolicy+".")
+	flag.BoolVar(&observeOnly, "observe-only", false,
+		"If set, changes are only planned and reported, but never written to Mailu. "+
+			"Can be overwritten per resource with the annotation "+controller.AnnotationObserveOnly+".")
+	flag.StringVar(&deletionPolicy, "deletion-policy", string(operatorv1alpha1.DeletionPolicyDelete),
+		"Default deletion policy for resources without spec.deletionPolicy: "+string(operatorv1alpha1.DeletionPolicyDelete)+
+			" deletes the object in Mailu, "+string(operatorv1alpha1.DeletionPolicyRetain)+" keeps it.")
+	flag.DurationVar(&pruneInterval, "prune-interval", 0,
+		"Interval in which all objects in Mailu are compared with the resources to find orphans, 0 disables pruning.")
+	flag.BoolVar(&pruneDelete, "prune-delete", false,
+		"If set, orphans in Mailu carrying the ownership marker of the operator or matching --prune-selector are deleted.")
+	flag.StringVar(&pruneSelector, "prune-selector", "",
+		"Pattern of orphans in Mailu to delete with --prune-delete, e.g. '*@example.com'.")
+	flag.BoolVar(&allowCrossNamespaceRefs, "allow-cross-namespace-refs", false,
+		"If set, aliases may reference users and aliases in other namespaces as destinations.")
+	flag.BoolVar(&restrictPrivilegedUsers, "restrict-privileged-users", false,
+		"If set, the webhooks only allow enabling globalAdmin and allowSpoofing of users in --privileged-namespaces, "+
+			"or to requesters allowed to update users/privileged in the namespace.")
+	flag.StringVar(&privilegedNamespaces, "privileged-namespaces", "",
+		"Comma-separated namespaces in which users may enable globalAdmin and allowSpoofing with --restrict-privileged-users.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -96,6 +137,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	// nolint:goconst
+	// the webhooks also serve the conversion between v1alpha1 and v1beta1
+	enableWebhooks := os.Getenv("ENABLE_WEBHOOKS") != "false"
+	if restrictPrivilegedUsers && !enableWebhooks {
+		setupLog.Error(errors.New("--restrict-privileged-users requires the webhooks"), "invalid configuration")
+		os.Exit(1)
+	}
+
+	if driftPolicy != controller.DriftPolicyCorrect && driftPolicy != controller.DriftPolicyReport {
+		setupLog.Error(errors.New("unknown drift policy "+driftPolicy), "invalid configuration")
+		os.Exit(1)
+	}
+
+	if deletionPolicy != string(operatorv1alpha1.DeletionPolicyDelete) &&
+		deletionPolicy != string(operatorv1alpha1.DeletionPolicyRetain) {
+		setupLog.Error(errors.New("unknown deletion policy "+deletionPolicy), "invalid configuration")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -116,6 +176,13 @@ func main() {
 		TLSOpts: tlsOpts,
 	})
 
+	// TODO: how to use current namespace?
+	watchNamespaces := []string{"mail"}
+	cacheNamespaces := map[string]cache.Config{}
+	for _, ns := range watchNamespaces {
+		cacheNamespaces[ns] = cache.Config{}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
@@ -139,8 +206,7 @@ func main() {
 		// after the manager stops then its usage might be unsafe.
 		// LeaderElectionReleaseOnCancel: true,
 		Cache: cache.Options{
-			// TODO: how to use current namespace?
-			DefaultNamespaces: map[string]cache.Config{"mail": {}},
+			DefaultNamespaces: cacheNamespaces,
 		},
 		// disable cache (and watch) for secrets
 		// nolint:lll
@@ -152,33 +218,118 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = controller.SetupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up field indexes")
+		os.Exit(1)
+	}
 	if err = (&controller.DomainReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		ApiURL:   mailuServer,
-		ApiToken: mailuToken,
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorder("domain-controller"),
+		ApiURL:         mailuServer,
+		ApiToken:       mailuToken,
+		ResyncInterval: resyncInterval,
+		DriftPolicy:    driftPolicy,
+		ObserveOnly:    observeOnly,
+		DeletionPolicy: operatorv1alpha1.DeletionPolicy(deletionPolicy),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create domain controller", "controller", "Domain")
 		os.Exit(1)
 	}
 	if err = (&controller.UserReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		ApiURL:   mailuServer,
-		ApiToken: mailuToken,
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorder("user-controller"),
+		ApiURL:         mailuServer,
+		ApiToken:       mailuToken,
+		ResyncInterval: resyncInterval,
+		DriftPolicy:    driftPolicy,
+		ObserveOnly:    observeOnly,
+		DeletionPolicy: operatorv1alpha1.DeletionPolicy(deletionPolicy),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create user controller", "controller", "User")
 		os.Exit(1)
 	}
 	if err = (&controller.AliasReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		ApiURL:   mailuServer,
-		ApiToken: mailuToken,
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorder("alias-controller"),
+		ApiURL:         mailuServer,
+		ApiToken:       mailuToken,
+		ResyncInterval: resyncInterval,
+		DriftPolicy:    driftPolicy,
+		ObserveOnly:    observeOnly,
+		DeletionPolicy: operatorv1alpha1.DeletionPolicy(deletionPolicy),
+
+		AllowCrossNamespaceRefs: allowCrossNamespaceRefs,
+		APIReader:               mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Alias")
 		os.Exit(1)
 	}
+	if err = (&controller.MailingListReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorder("mailinglist-controller"),
+		ApiURL:         mailuServer,
+		ApiToken:       mailuToken,
+		ResyncInterval: resyncInterval,
+		ObserveOnly:    observeOnly,
+		DeletionPolicy: operatorv1alpha1.DeletionPolicy(deletionPolicy),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MailingList")
+		os.Exit(1)
+	}
+	if err = (&controller.AutoReplyReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorder("autoreply-controller"),
+		ApiURL:         mailuServer,
+		ApiToken:       mailuToken,
+		ResyncInterval: resyncInterval,
+		ObserveOnly:    observeOnly,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AutoReply")
+		os.Exit(1)
+	}
+	if err = (&controller.Pruner{
+		Client:      mgr.GetClient(),
+		ApiURL:      mailuServer,
+		ApiToken:    mailuToken,
+		Interval:    pruneInterval,
+		Delete:      pruneDelete,
+		Selector:    pruneSelector,
+		ObserveOnly: observeOnly,
+		Namespaces:  watchNamespaces,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create pruner")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		var privilegedPolicy *webhookv1alpha1.PrivilegedUserPolicy
+		if restrictPrivilegedUsers {
+			privilegedPolicy = &webhookv1alpha1.PrivilegedUserPolicy{
+				Namespaces: strings.FieldsFunc(privilegedNamespaces, func(r rune) bool { return r == ',' || r == ' ' }),
+				Client:     mgr.GetClient(),
+			}
+		}
+		if err = webhookv1alpha1.SetupDomainWebhookWithManager(mgr, privilegedPolicy); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Domain")
+			os.Exit(1)
+		}
+		if err = webhookv1alpha1.SetupUserWebhookWithManager(mgr, privilegedPolicy); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "User")
+			os.Exit(1)
+		}
+		if err = webhookv1alpha1.SetupAliasWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Alias")
+			os.Exit(1)
+		}
+		if err = webhookv1alpha1.SetupAutoReplyWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AutoReply")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -8,12 +8,16 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -31,10 +35,19 @@ const (
 // AliasReconciler reconciles a Alias object
 type AliasReconciler struct {
 	client.Client
-	Scheme    *runtime.Scheme
-	ApiURL    string
-	ApiToken  string
-	ApiClient *mailu.Client
+	Scheme         *runtime.Scheme
+	Recorder       events.EventRecorder
+	ApiURL         string
+	ApiToken       string
+	ApiClient      *mailu.Client
+	ResyncInterval time.Duration
+	DriftPolicy    string
+	ObserveOnly    bool
+	DeletionPolicy operatorv1alpha1.DeletionPolicy
+	// AllowCrossNamespaceRefs allows destination references to Users and Aliases in other namespaces.
+	AllowCrossNamespaceRefs bool
+	// APIReader reads the references to other namespaces, which are not in the cache. The Client is used if not set.
+	APIReader client.Reader
 }
 
 //+kubebuilder:rbac:groups=operator.mailu.io,resources=aliases,verbs=get;list;watch;create;update;patch;delete
@@ -65,6 +78,31 @@ func (r *AliasReconciler) Reconcile(ctx context.Context, alias *operatorv1alpha1
 		controllerutil.AddFinalizer(alias, FinalizerName)
 	}
 
+	// skip all calls to MailU, including the deletion, until the annotation is removed
+	if paused(alias) {
+		meta.SetStatusCondition(&alias.Status.Conditions, getPausedCondition())
+		logr.Info("reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypePaused)
+
+	// only the oldest resource targeting an object in MailU manages it, the others must not call MailU
+	winner, err := findConflictWinner(ctx, r.Client, "Alias", alias)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if winner != "" {
+		msg := fmt.Sprintf("%s is already managed by %s", alias.Spec.Name+"@"+alias.Spec.Domain, winner)
+		meta.SetStatusCondition(&alias.Status.Conditions, getConflictCondition(msg))
+		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "Conflict", msg))
+		logr.Info("conflicting resource, skipping reconciliation", "winner", winner)
+		if aliasOriginal.DeletionTimestamp != nil {
+			controllerutil.RemoveFinalizer(alias, FinalizerName)
+		}
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypeConflict)
+
 	result, err := r.reconcile(ctx, alias)
 	if err != nil {
 		return result, err
@@ -91,7 +129,30 @@ func (r *AliasReconciler) reconcile(ctx context.Context, alias *operatorv1alpha1
 		r.ApiClient = api
 	}
 
-	foundAlias, retry, err := r.getAlias(ctx, alias)
+	if !observeOnly(alias, r.ObserveOnly) {
+		meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypeObserveOnly)
+	}
+
+	if alias.DeletionTimestamp != nil && deletionPolicy(alias.Spec.DeletionPolicy, r.DeletionPolicy) == operatorv1alpha1.DeletionPolicyRetain {
+		return r.retain(ctx, alias)
+	}
+
+	email := alias.Spec.Name + "@" + alias.Spec.Domain
+	if renamed(alias.Status.AppliedAddress, email) && alias.DeletionTimestamp == nil &&
+		alias.Spec.RenamePolicy != operatorv1alpha1.RenamePolicyRecreate {
+		msg := fmt.Sprintf("Alias cannot be renamed from %s to %s, revert the change or set renamePolicy Recreate", alias.Status.AppliedAddress, email)
+		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "RenameRejected", msg))
+		logr.Info("rejecting rename of alias", "from", alias.Status.AppliedAddress, "to", email)
+		return ctrl.Result{}, nil
+	}
+
+	// a deleted alias is removed at the address it was applied with, which may differ from the spec
+	address := email
+	if alias.DeletionTimestamp != nil && alias.Status.AppliedAddress != "" {
+		address = alias.Status.AppliedAddress
+	}
+
+	foundAlias, retry, err := getAlias(ctx, r.ApiClient, address)
 	if err != nil {
 		if retry {
 			logr.Info(fmt.Errorf("failed to get alias, requeueing: %w", err).Error())
@@ -104,24 +165,93 @@ func (r *AliasReconciler) reconcile(ctx context.Context, alias *operatorv1alpha1
 	}
 
 	if alias.DeletionTimestamp != nil {
-		if foundAlias == nil {
-			// no need to delete it, if it does not exist
+		if foundAlias == nil || (alias.Status.ObservedGeneration == 0 && !ownedBy(foundAlias.Comment, alias)) {
+			// no need to delete it, if it does not exist or was never applied by this resource
 			return ctrl.Result{}, nil
 		}
-		return r.delete(ctx, alias)
+		return r.delete(ctx, alias, address)
 	}
 
+	uncached := r.APIReader
+	if uncached == nil {
+		uncached = r.Client
+	}
+	dest, err := resolveDestinations(ctx, r.Client, uncached, alias, r.AllowCrossNamespaceRefs)
+	if err != nil {
+		logr.Info(fmt.Errorf("failed to resolve destination references, requeueing: %w", err).Error())
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+	setDestinationsResolvedCondition(alias, dest)
+
+	var result ctrl.Result
 	if foundAlias == nil {
-		return r.create(ctx, alias)
+		// the alias was applied before at this address, so it has been deleted in MailU
+		if alias.Status.ObservedGeneration > 0 && !renamed(alias.Status.AppliedAddress, email) {
+			meta.SetStatusCondition(&alias.Status.Conditions, getDriftedCondition("Alias was deleted in MailU"))
+			if driftPolicy(alias, r.DriftPolicy) == DriftPolicyReport {
+				recordEvent(r.Recorder, alias, corev1.EventTypeWarning, "Drifted", "Report", "Alias was deleted in MailU")
+				logr.Info("alias was deleted in MailU, not recreating it")
+				return ctrl.Result{RequeueAfter: resyncInterval(alias, r.ResyncInterval)}, nil
+			}
+			recordEvent(r.Recorder, alias, corev1.EventTypeWarning, "Drifted", "Correct", "Alias was deleted in MailU, recreating it")
+		}
+		if result, wait := waitForDomain(ctx, r.Client, r.ApiClient, alias, &alias.Status.Conditions, AliasConditionTypeReady, resyncInterval(alias, r.ResyncInterval)); wait {
+			return result, nil
+		}
+		result, err = r.create(ctx, alias, dest)
+	} else {
+		result, err = r.update(ctx, alias, foundAlias, dest)
+	}
+	if err != nil || alias.Status.ObservedGeneration != alias.Generation {
+		return result, err
 	}
 
-	return r.update(ctx, alias, foundAlias)
+	return r.applied(ctx, alias, result)
 }
 
-func (r *AliasReconciler) create(ctx context.Context, alias *operatorv1alpha1.Alias) (ctrl.Result, error) {
+// applied records the address of the applied alias. After a rename, the alias at the previous address is deleted.
+func (r *AliasReconciler) applied(ctx context.Context, alias *operatorv1alpha1.Alias, result ctrl.Result) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
-	retry, err := r.createAlias(ctx, alias)
+	email := alias.Spec.Name + "@" + alias.Spec.Domain
+	if renamed(alias.Status.AppliedAddress, email) {
+		if observeOnly(alias, r.ObserveOnly) {
+			reportPlan(r.Recorder, alias, &alias.Status.Conditions, "Delete", "Would delete renamed alias "+alias.Status.AppliedAddress+" in MailU")
+			logr.Info("observe-only, not deleting renamed alias")
+			return result, nil
+		}
+
+		retry, err := deleteAlias(ctx, r.ApiClient, alias.Status.AppliedAddress)
+		if err != nil {
+			meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+			if retry {
+				logr.Info(fmt.Errorf("failed to delete renamed alias, requeueing: %w", err).Error())
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			logr.Error(err, "failed to delete renamed alias")
+			return ctrl.Result{}, err
+		}
+		if retry {
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		recordEvent(r.Recorder, alias, corev1.EventTypeNormal, "Renamed", "Recreate", "Alias %s was recreated as %s", alias.Status.AppliedAddress, email)
+		logr.Info("deleted renamed alias", "from", alias.Status.AppliedAddress, "to", email)
+	}
+
+	alias.Status.AppliedAddress = email
+	return result, nil
+}
+
+func (r *AliasReconciler) create(ctx context.Context, alias *operatorv1alpha1.Alias, dest aliasDestinations) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if observeOnly(alias, r.ObserveOnly) {
+		reportPlan(r.Recorder, alias, &alias.Status.Conditions, "Create", "Would create alias "+alias.Spec.Name+"@"+alias.Spec.Domain+" in MailU")
+		logr.Info("observe-only, not creating alias")
+		return ctrl.Result{RequeueAfter: resyncInterval(alias, r.ResyncInterval)}, nil
+	}
+
+	retry, err := createAlias(ctx, r.ApiClient, aliasFromSpec(alias, dest))
 	if err != nil {
 		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
 		if retry {
@@ -136,32 +266,83 @@ func (r *AliasReconciler) create(ctx context.Context, alias *operatorv1alpha1.Al
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
+	meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypeDrifted)
 	meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionTrue, "Created", "Alias created in MailU"))
+	alias.Status.ObservedGeneration = alias.Generation
+	alias.Status.ResolvedDestinations = dest.refs
 	logr.Info("created alias")
+	r.validate(ctx, alias, dest.all)
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: resyncInterval(alias, r.ResyncInterval)}, nil
 }
 
-func (r *AliasReconciler) update(ctx context.Context, alias *operatorv1alpha1.Alias, apiAlias *mailu.Alias) (ctrl.Result, error) {
+func (r *AliasReconciler) update(ctx context.Context, alias *operatorv1alpha1.Alias, apiAlias *mailu.Alias, dest aliasDestinations) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
-	newAlias := mailu.Alias{
-		Email:       alias.Spec.Name + "@" + alias.Spec.Domain,
-		Comment:     &alias.Spec.Comment,
-		Destination: &alias.Spec.Destination,
-		Wildcard:    &alias.Spec.Wildcard,
+	// keep the values of ignored fields as they are in MailU
+	newAlias, err := ignoreFields(aliasFromSpec(alias, dest), *apiAlias, aliasFields, alias.Spec.IgnoreFields)
+	if err != nil {
+		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		logr.Error(err, "failed to apply ignored fields")
+		return ctrl.Result{}, err
+	}
+	var destinations []string
+	if newAlias.Destination != nil {
+		destinations = *newAlias.Destination
+	}
+
+	// the alias exists in MailU, but was never applied by this resource (at this address)
+	if (alias.Status.ObservedGeneration == 0 || renamed(alias.Status.AppliedAddress, newAlias.Email)) && !ownedBy(apiAlias.Comment, alias) {
+		diff := withoutOwnerMarkerDiff(diffFields(newAlias, *apiAlias, aliasFields), newAlias.Comment, apiAlias.Comment)
+		reason, message := checkAdoption(alias.Spec.AdoptionPolicy, "Alias", diff)
+		if reason != "" {
+			meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, reason, message))
+			logr.Info("not adopting existing alias: " + message)
+			return ctrl.Result{}, nil
+		}
+		meta.SetStatusCondition(&alias.Status.Conditions, getAdoptedCondition("Alias adopted from MailU"))
+		recordEvent(r.Recorder, alias, corev1.EventTypeNormal, "Adopted", "Adopt", "Adopted existing alias %s from MailU", alias.Spec.Name+"@"+alias.Spec.Domain)
+		logr.Info("adopting existing alias")
 	}
 
 	jsonNew, _ := json.Marshal(newAlias) //nolint:errcheck
 	jsonOld, _ := json.Marshal(apiAlias) //nolint:errcheck
 
 	if reflect.DeepEqual(jsonNew, jsonOld) {
+		meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypeDrifted)
 		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionTrue, "Updated", "Alias updated in MailU"))
+		alias.Status.ObservedGeneration = alias.Generation
+		alias.Status.ResolvedDestinations = dest.refs
+		r.validate(ctx, alias, destinations)
+		if observeOnly(alias, r.ObserveOnly) {
+			reportPlan(r.Recorder, alias, &alias.Status.Conditions, "None", "Alias is up to date in MailU")
+		}
 		logr.Info("alias is up to date, no change needed")
-		return ctrl.Result{}, nil
+		return ctrl.Result{RequeueAfter: resyncInterval(alias, r.ResyncInterval)}, nil
 	}
 
-	retry, err := r.updateAlias(ctx, newAlias)
+	// the spec and the referenced destinations did not change since they were last applied, so the alias has been
+	// changed in MailU (a missing ownership marker is no drift, it is added with the next update)
+	drift := withoutOwnerMarkerDiff(diffFields(newAlias, *apiAlias, aliasFields), newAlias.Comment, apiAlias.Comment)
+	if alias.Status.ObservedGeneration == alias.Generation && slices.Equal(alias.Status.ResolvedDestinations, dest.refs) && len(drift) > 0 {
+		fields := strings.Join(drift, ", ")
+		meta.SetStatusCondition(&alias.Status.Conditions, getDriftedCondition("Alias differs in MailU: "+fields))
+		if driftPolicy(alias, r.DriftPolicy) == DriftPolicyReport {
+			recordEvent(r.Recorder, alias, corev1.EventTypeWarning, "Drifted", "Report", "Alias differs in MailU: %s", fields)
+			logr.Info("alias differs in MailU, not correcting it", "fields", fields)
+			return ctrl.Result{RequeueAfter: resyncInterval(alias, r.ResyncInterval)}, nil
+		}
+		recordEvent(r.Recorder, alias, corev1.EventTypeWarning, "Drifted", "Correct", "Alias differs in MailU, correcting: %s", fields)
+	}
+
+	if observeOnly(alias, r.ObserveOnly) {
+		fields := strings.Join(diffFields(newAlias, *apiAlias, aliasFields), ", ")
+		reportPlan(r.Recorder, alias, &alias.Status.Conditions, "Update", "Would update alias "+alias.Spec.Name+"@"+alias.Spec.Domain+" in MailU: "+fields)
+		logr.Info("observe-only, not updating alias", "fields", fields)
+		return ctrl.Result{RequeueAfter: resyncInterval(alias, r.ResyncInterval)}, nil
+	}
+
+	retry, err := updateAlias(ctx, r.ApiClient, newAlias)
 	if err != nil {
 		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
 		if retry {
@@ -177,15 +358,34 @@ func (r *AliasReconciler) update(ctx context.Context, alias *operatorv1alpha1.Al
 	}
 
 	logr.Info("updated alias")
+	meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypeDrifted)
 	meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionTrue, "Updated", "Alias updated in MailU"))
+	alias.Status.ObservedGeneration = alias.Generation
+	alias.Status.ResolvedDestinations = dest.refs
+	r.validate(ctx, alias, destinations)
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: resyncInterval(alias, r.ResyncInterval)}, nil
 }
 
-func (r *AliasReconciler) delete(ctx context.Context, alias *operatorv1alpha1.Alias) (ctrl.Result, error) {
+// validate records the state of the applied destinations in MailU and reports the ones that do not exist.
+func (r *AliasReconciler) validate(ctx context.Context, alias *operatorv1alpha1.Alias, destinations []string) {
+	alias.Status.Destinations = validateDestinations(ctx, r.ApiClient, destinations)
+	setDegradedCondition(alias)
+	if meta.IsStatusConditionTrue(alias.Status.Conditions, ConditionTypeDegraded) {
+		log.FromContext(ctx).Info("alias has invalid destinations", "message", meta.FindStatusCondition(alias.Status.Conditions, ConditionTypeDegraded).Message)
+	}
+}
+
+func (r *AliasReconciler) delete(ctx context.Context, alias *operatorv1alpha1.Alias, email string) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
-	retry, err := r.deleteAlias(ctx, alias)
+	if observeOnly(alias, r.ObserveOnly) {
+		reportPlan(r.Recorder, alias, &alias.Status.Conditions, "Delete", "Would delete alias "+email+" in MailU")
+		logr.Info("observe-only, not deleting alias")
+		return ctrl.Result{}, nil
+	}
+
+	retry, err := deleteAlias(ctx, r.ApiClient, email)
 	if err != nil {
 		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
 		if retry {
@@ -205,8 +405,8 @@ func (r *AliasReconciler) delete(ctx context.Context, alias *operatorv1alpha1.Al
 	return ctrl.Result{}, nil
 }
 
-func (r *AliasReconciler) getAlias(ctx context.Context, alias *operatorv1alpha1.Alias) (*mailu.Alias, bool, error) {
-	found, err := r.ApiClient.FindAlias(ctx, alias.Spec.Name+"@"+alias.Spec.Domain)
+func getAlias(ctx context.Context, api *mailu.Client, email string) (*mailu.Alias, bool, error) {
+	found, err := api.FindAlias(ctx, email)
 	if err != nil {
 		return nil, false, err
 	}
@@ -240,13 +440,19 @@ func (r *AliasReconciler) getAlias(ctx context.Context, alias *operatorv1alpha1.
 	return nil, false, errors.New("unknown status: " + strconv.Itoa(found.StatusCode))
 }
 
-func (r *AliasReconciler) createAlias(ctx context.Context, alias *operatorv1alpha1.Alias) (bool, error) {
-	res, err := r.ApiClient.CreateAlias(ctx, mailu.Alias{
+// aliasFromSpec returns the alias in MailU as defined by the spec, with the resolved destinations.
+func aliasFromSpec(alias *operatorv1alpha1.Alias, dest aliasDestinations) mailu.Alias {
+	comment := withOwnerMarker(alias.Spec.Comment, alias)
+	return mailu.Alias{
 		Email:       alias.Spec.Name + "@" + alias.Spec.Domain,
-		Comment:     &alias.Spec.Comment,
-		Destination: &alias.Spec.Destination,
+		Comment:     &comment,
+		Destination: &dest.all,
 		Wildcard:    &alias.Spec.Wildcard,
-	})
+	}
+}
+
+func createAlias(ctx context.Context, api *mailu.Client, newAlias mailu.Alias) (bool, error) {
+	res, err := api.CreateAlias(ctx, newAlias)
 	if err != nil {
 		return false, err
 	}
@@ -277,8 +483,8 @@ func (r *AliasReconciler) createAlias(ctx context.Context, alias *operatorv1alph
 	return false, errors.New("unknown status: " + strconv.Itoa(res.StatusCode))
 }
 
-func (r *AliasReconciler) updateAlias(ctx context.Context, newAlias mailu.Alias) (bool, error) {
-	res, err := r.ApiClient.UpdateAlias(ctx, newAlias.Email, newAlias)
+func updateAlias(ctx context.Context, api *mailu.Client, newAlias mailu.Alias) (bool, error) {
+	res, err := api.UpdateAlias(ctx, newAlias.Email, newAlias)
 	if err != nil {
 		return false, err
 	}
@@ -307,8 +513,8 @@ func (r *AliasReconciler) updateAlias(ctx context.Context, newAlias mailu.Alias)
 	return false, errors.New("unknown status: " + strconv.Itoa(res.StatusCode))
 }
 
-func (r *AliasReconciler) deleteAlias(ctx context.Context, alias *operatorv1alpha1.Alias) (bool, error) {
-	res, err := r.ApiClient.DeleteAlias(ctx, alias.Spec.Name+"@"+alias.Spec.Domain)
+func deleteAlias(ctx context.Context, api *mailu.Client, email string) (bool, error) {
+	res, err := api.DeleteAlias(ctx, email)
 	if err != nil {
 		return false, err
 	}
@@ -350,5 +556,11 @@ func getAliasReadyCondition(status metav1.ConditionStatus, reason, message strin
 func (r *AliasReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&operatorv1alpha1.Alias{}).
+		Watches(&operatorv1alpha1.User{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.AliasList{})).
+		Watches(&operatorv1alpha1.Alias{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.AliasList{})).
+		Watches(&operatorv1alpha1.MailingList{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.AliasList{})).
+		Watches(&operatorv1alpha1.Domain{}, enqueueWaitingForDomain(mgr.GetClient(), &operatorv1alpha1.AliasList{})).
+		Watches(&operatorv1alpha1.User{}, enqueueReferencingAliases(mgr.GetClient())).
+		Watches(&operatorv1alpha1.Alias{}, enqueueReferencingAliases(mgr.GetClient())).
 		Complete(reconcile.AsReconciler(r.Client, r))
 }
@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/ghttp"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -71,6 +72,7 @@ var _ = Describe("Alias Controller", func() {
 
 			It("updates the status, if creation fails", func() {
 				prepareFindAlias(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
 				prepareCreateAlias(res, http.StatusServiceUnavailable)
 
 				result, err := reconcile(false)
@@ -85,7 +87,9 @@ var _ = Describe("Alias Controller", func() {
 			It("creates the alias, updates status and adds a finalizer", func() {
 				res = resAfterReconciliation.DeepCopy()
 				prepareFindAlias(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
 				prepareCreateAlias(res, http.StatusOK)
+				prepareValidateDestinations(http.StatusOK, res.Spec.Destination...)
 
 				_, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
@@ -93,6 +97,8 @@ var _ = Describe("Alias Controller", func() {
 				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
 				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(1))
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, AliasConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.Destinations).To(HaveLen(1))
+				Expect(resAfterReconciliation.Status.Destinations[0].State).To(Equal(operatorv1alpha1.DestinationStateExists))
 			})
 
 			It("requeues the request, if a retryable error occurs", func() {
@@ -124,14 +130,16 @@ var _ = Describe("Alias Controller", func() {
 			It("updates the status, if creation fails with conflict", func() {
 				res = resAfterReconciliation.DeepCopy()
 				prepareFindAlias(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
 				prepareCreateAlias(res, http.StatusConflict)
 
 				result, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
-				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(1))
+				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(2))
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, AliasConditionTypeReady)).To(BeFalse())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeDrifted)).To(BeTrue())
 				Expect(result.RequeueAfter).To(BeNumerically(">", 0))
 			})
 		})
@@ -150,6 +158,7 @@ var _ = Describe("Alias Controller", func() {
 			It("updates the alias", func() {
 				prepareFindAlias(resAfterReconciliation, http.StatusOK)
 				preparePatchAlias(res, http.StatusOK)
+				prepareValidateDestinations(http.StatusOK, res.Spec.Destination...)
 
 				_, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
@@ -161,6 +170,7 @@ var _ = Describe("Alias Controller", func() {
 			It("does nothing, if there is no change", func() {
 				res = resAfterReconciliation.DeepCopy()
 				prepareFindAlias(res, http.StatusOK)
+				prepareValidateDestinations(http.StatusOK, res.Spec.Destination...)
 
 				_, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
@@ -238,14 +248,21 @@ var _ = Describe("Alias Controller", func() {
 				Expect(res.GetFinalizers()).To(HaveLen(0))
 				Expect(res.Status.Conditions).To(HaveLen(0))
 
-				prepareFindAlias(res, http.StatusOK)
+				// the existing alias was not created by the operator, so it carries no ownership marker
+				existing := res.DeepCopy()
+				existing.UID = ""
+				prepareFindAlias(existing, http.StatusOK)
+				// the ownership marker is added to the comment
+				preparePatchAlias(res, http.StatusOK)
+				prepareValidateDestinations(http.StatusOK, res.Spec.Destination...)
 
 				_, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
-				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(1))
+				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(2))
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, AliasConditionTypeReady)).To(BeTrue())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeAdopted)).To(BeTrue())
 			})
 		})
 
@@ -268,5 +285,173 @@ var _ = Describe("Alias Controller", func() {
 				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.Alias{}))
 			})
 		})
+
+		When("creating an Alias that already exists with adoption policy FailIfExists", func() {
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.Alias{}, "existing", domain).(*operatorv1alpha1.Alias)
+				res.Spec.AdoptionPolicy = operatorv1alpha1.AdoptionPolicyFailIfExists
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not adopt the existing alias", func() {
+				existing := res.DeepCopy()
+				existing.UID = ""
+				prepareFindAlias(existing, http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, AliasConditionTypeReady)).To(BeFalse())
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, AliasConditionTypeReady)
+				Expect(condition.Reason).To(Equal("AlreadyExists"))
+				Expect(meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeAdopted)).To(BeNil())
+			})
+
+			It("does not delete the existing alias", func() {
+				res = resAfterReconciliation.DeepCopy()
+				err := k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				// it is not owned by this resource
+				existing := res.DeepCopy()
+				existing.UID = ""
+				prepareFindAlias(existing, http.StatusOK)
+
+				_, err = reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.Alias{}))
+			})
+		})
+
+		When("managing an Alias in observe-only mode", func() {
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.Alias{}, "observed", domain).(*operatorv1alpha1.Alias)
+				res.Annotations = map[string]string{AnnotationObserveOnly: "true"}
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("reports the creation without creating the alias", func() {
+				prepareFindAlias(res, http.StatusNotFound)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeObserveOnly)).To(BeTrue())
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeObserveOnly)
+				Expect(condition.Reason).To(Equal("Create"))
+			})
+
+			It("reports the update without updating the alias", func() {
+				res = resAfterReconciliation.DeepCopy()
+				apiAlias := res.DeepCopy()
+				apiAlias.Spec.Comment = "changed in MailU"
+				prepareFindAlias(apiAlias, http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeObserveOnly)
+				Expect(condition.Reason).To(Equal("Update"))
+				Expect(condition.Message).To(ContainSubstring("comment"))
+			})
+
+			It("reports the deletion without deleting the alias", func() {
+				res = resAfterReconciliation.DeepCopy()
+				err := k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindAlias(res, http.StatusOK)
+
+				_, err = reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.Alias{}))
+			})
+		})
+
+		When("creating an Alias with destination references", func() {
+			var user *operatorv1alpha1.User
+
+			BeforeAll(func() {
+				user = CreateResource(operatorv1alpha1.User{}, "referenced", domain).(*operatorv1alpha1.User)
+				err := k8sClient.Create(ctx, user)
+				Expect(err).ToNot(HaveOccurred())
+
+				res = CreateResource(operatorv1alpha1.Alias{}, "team", domain).(*operatorv1alpha1.Alias)
+				res.Spec.DestinationRefs = []operatorv1alpha1.DestinationRef{
+					{Kind: "User", Name: user.Name},
+					{Kind: "User", Name: "missing"},
+					{Kind: "User", Name: user.Name, Namespace: "other"},
+				}
+				err = k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				Expect(k8sClient.Delete(ctx, user)).To(Succeed())
+			})
+
+			It("creates the alias with the resolved destinations and reports the unresolved ones", func() {
+				expected := res.DeepCopy()
+				expected.Spec.Destination = append(expected.Spec.Destination, user.Spec.Name+"@"+user.Spec.Domain)
+				prepareFindAlias(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
+				prepareCreateAlias(expected, http.StatusOK)
+				prepareValidateDestinations(http.StatusOK, expected.Spec.Destination...)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, AliasConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.ResolvedDestinations).To(Equal([]string{user.Spec.Name + "@" + user.Spec.Domain}))
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeDestinationsResolved)
+				Expect(condition).ToNot(BeNil())
+				Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+				Expect(condition.Message).To(ContainSubstring("User default/missing not found"))
+				Expect(condition.Message).To(ContainSubstring("User other/referenced is in another namespace"))
+			})
+
+			It("updates the destinations, if the referenced user is renamed", func() {
+				user.Spec.Name = "renamed"
+				Expect(k8sClient.Update(ctx, user)).To(Succeed())
+
+				res = resAfterReconciliation.DeepCopy()
+				prepareFindAlias(res, http.StatusOK)
+				preparePatchAlias(res, http.StatusOK)
+				prepareValidateDestinations(http.StatusOK, res.Spec.Destination...)
+				// the renamed user does not exist in MailU yet
+				mock.AppendHandlers(
+					ghttp.CombineHandlers(ghttp.VerifyRequest("GET", "/user/renamed@"+user.Spec.Domain), ResponseNotFound),
+					ghttp.CombineHandlers(ghttp.VerifyRequest("GET", "/alias/renamed@"+user.Spec.Domain), ResponseNotFound),
+				)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeDrifted)).To(BeNil())
+				Expect(resAfterReconciliation.Status.ResolvedDestinations).To(Equal([]string{"renamed@" + user.Spec.Domain}))
+				Expect(resAfterReconciliation.Status.Destinations).To(HaveLen(2))
+				Expect(resAfterReconciliation.Status.Destinations[1].State).To(Equal(operatorv1alpha1.DestinationStateMissing))
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeDegraded)).To(BeTrue())
+			})
+		})
 	})
 })
@@ -0,0 +1,464 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	openapitypes "github.com/oapi-codegen/runtime/types"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+const (
+	AutoReplyConditionTypeReady = "AutoReplyReady"
+
+	// IndexAutoReplyUser is the field index of AutoReplies on the User they reference, as 'namespace/name'.
+	IndexAutoReplyUser = "spec.userName"
+)
+
+// replyFields maps the auto-reply field names of UserSpec to the field names of the Mailu API.
+var replyFields = map[string]string{
+	"replyEnabled":   "reply_enabled",
+	"replySubject":   "reply_subject",
+	"replyBody":      "reply_body",
+	"replyStartDate": "reply_startdate",
+	"replyEndDate":   "reply_enddate",
+}
+
+// AutoReplyReconciler reconciles an AutoReply object
+type AutoReplyReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       events.EventRecorder
+	ApiURL         string
+	ApiToken       string
+	ApiClient      *mailu.Client
+	ResyncInterval time.Duration
+	ObserveOnly    bool
+}
+
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=autoreplies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=autoreplies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=autoreplies/finalizers,verbs=update
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=users,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// The auto-reply enables the replies of its user in MailU while one of its windows is active, disables them
+// otherwise, and is reconciled again at the next start or end of a window.
+func (r *AutoReplyReconciler) Reconcile(ctx context.Context, reply *operatorv1alpha1.AutoReply) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	replyOriginal := reply.DeepCopy()
+
+	// apply patches at the end, before returning
+	defer func() {
+		if err := r.Patch(ctx, reply.DeepCopy(), client.MergeFrom(replyOriginal)); err != nil {
+			logr.Error(err, "failed to patch resource")
+		}
+		if err := r.Status().Patch(ctx, reply.DeepCopy(), client.MergeFrom(replyOriginal)); err != nil {
+			logr.Error(err, "failed to patch resource status")
+		}
+	}()
+
+	if reply.DeletionTimestamp == nil && !controllerutil.ContainsFinalizer(reply, FinalizerName) {
+		controllerutil.AddFinalizer(reply, FinalizerName)
+	}
+
+	// skip all calls to MailU, including the deletion, until the annotation is removed
+	if paused(reply) {
+		meta.SetStatusCondition(&reply.Status.Conditions, getPausedCondition())
+		logr.Info("reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&reply.Status.Conditions, ConditionTypePaused)
+
+	// only the oldest auto-reply of a user manages its replies, the others must not call MailU
+	winner, err := findConflictWinner(ctx, r.Client, "AutoReply", reply)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if winner != "" {
+		msg := fmt.Sprintf("replies of User %s are already managed by %s", reply.Spec.UserName, winner)
+		meta.SetStatusCondition(&reply.Status.Conditions, getConflictCondition(msg))
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "Conflict", msg))
+		logr.Info("conflicting resource, skipping reconciliation", "winner", winner)
+		if replyOriginal.DeletionTimestamp != nil {
+			controllerutil.RemoveFinalizer(reply, FinalizerName)
+		}
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&reply.Status.Conditions, ConditionTypeConflict)
+
+	result, err := r.reconcile(ctx, reply)
+	if err != nil {
+		return result, err
+	}
+
+	if replyOriginal.DeletionTimestamp != nil && result.RequeueAfter == 0 {
+		controllerutil.RemoveFinalizer(reply, FinalizerName)
+	}
+
+	return result, nil
+}
+
+func (r *AutoReplyReconciler) reconcile(ctx context.Context, reply *operatorv1alpha1.AutoReply) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if r.ApiClient == nil {
+		api, err := mailu.NewClient(r.ApiURL, mailu.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			req.Header.Add("Authorization", "Bearer "+r.ApiToken)
+			return nil
+		}))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.ApiClient = api
+	}
+
+	if !observeOnly(reply, r.ObserveOnly) {
+		meta.RemoveStatusCondition(&reply.Status.Conditions, ConditionTypeObserveOnly)
+	}
+
+	user := &operatorv1alpha1.User{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: reply.Namespace, Name: reply.Spec.UserName}, user); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		user = nil
+	}
+
+	if reply.DeletionTimestamp != nil {
+		return r.delete(ctx, reply, user)
+	}
+
+	if user == nil || !meta.IsStatusConditionTrue(user.Status.Conditions, UserConditionTypeReady) {
+		msg := fmt.Sprintf("User %s is not ready", reply.Spec.UserName)
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "UserNotReady", msg))
+		logr.Info("waiting for user", "user", reply.Spec.UserName)
+		return ctrl.Result{}, nil
+	}
+
+	address := user.Spec.Name + "@" + user.Spec.Domain
+	apiUser, retry, err := getUser(ctx, r.ApiClient, address)
+	if err != nil {
+		if retry {
+			logr.Info(fmt.Errorf("failed to get user, requeueing: %w", err).Error())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		// we explicitly set the error in the status only on a permanent (non-retryable) error
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		logr.Error(err, "failed to get user")
+		return ctrl.Result{}, nil
+	}
+	if apiUser == nil {
+		msg := fmt.Sprintf("User %s does not exist in MailU", address)
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "UserNotReady", msg))
+		logr.Info("waiting for user in MailU", "user", address)
+		return ctrl.Result{RequeueAfter: dependencyCheckInterval(reply, r.ResyncInterval)}, nil
+	}
+
+	now := time.Now()
+	active, next := autoReplySchedule(reply.Spec.Windows, now)
+	newUser, err := autoReplyUser(reply, user, apiUser, active)
+	if err != nil {
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "InvalidTemplate", err.Error()))
+		logr.Error(err, "failed to render auto-reply")
+		return ctrl.Result{}, nil
+	}
+
+	result := requeueAt(ctrl.Result{RequeueAfter: resyncInterval(reply, r.ResyncInterval)}, next, now)
+
+	if diff := diffFields(newUser, *apiUser, replyFields); len(diff) > 0 {
+		if observeOnly(reply, r.ObserveOnly) {
+			reportPlan(r.Recorder, reply, &reply.Status.Conditions, "Update", "Would update auto-reply of user "+address+" in MailU: "+strings.Join(diff, ", "))
+			logr.Info("observe-only, not updating auto-reply", "fields", diff)
+			return result, nil
+		}
+		retry, err := updateUser(ctx, r.ApiClient, newUser)
+		if err != nil {
+			meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+			if retry {
+				logr.Info(fmt.Errorf("failed to update auto-reply, requeueing: %w", err).Error())
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			logr.Error(err, "failed to update auto-reply")
+			return ctrl.Result{}, err
+		}
+		if retry {
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		logr.Info("updated auto-reply", "fields", diff)
+	} else if observeOnly(reply, r.ObserveOnly) {
+		reportPlan(r.Recorder, reply, &reply.Status.Conditions, "None", "Auto-reply is up to date in MailU")
+	}
+
+	wasActive := reply.Status.ActiveWindow != nil
+	if active >= 0 {
+		reply.Status.ActiveWindow = &active
+		end := reply.Spec.Windows[active].End.UTC().Format(time.RFC3339)
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionTrue, "Active", "Auto-reply enabled in MailU until "+end))
+		if !wasActive {
+			recordEvent(r.Recorder, reply, corev1.EventTypeNormal, "ReplyEnabled", "Update", "Auto-reply of %s enabled until %s", address, end)
+		}
+	} else {
+		reply.Status.ActiveWindow = nil
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionTrue, "Inactive", "Auto-reply disabled in MailU"))
+		if wasActive {
+			recordEvent(r.Recorder, reply, corev1.EventTypeNormal, "ReplyDisabled", "Update", "Auto-reply of %s disabled", address)
+		}
+	}
+	reply.Status.NextTransition = nil
+	if !next.IsZero() {
+		reply.Status.NextTransition = &metav1.Time{Time: next}
+	}
+	reply.Status.ObservedGeneration = reply.Generation
+	reply.Status.AppliedAddress = address
+
+	return result, nil
+}
+
+// delete hands the replies of the user back to the User resource: the reply fields of its spec are applied again,
+// or the replies are disabled if the User resource is gone.
+func (r *AutoReplyReconciler) delete(ctx context.Context, reply *operatorv1alpha1.AutoReply, user *operatorv1alpha1.User) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	address := reply.Status.AppliedAddress
+	if address == "" {
+		// no need to reset it, if it was never applied by this resource
+		return ctrl.Result{}, nil
+	}
+
+	apiUser, retry, err := getUser(ctx, r.ApiClient, address)
+	if err != nil {
+		if retry {
+			logr.Info(fmt.Errorf("failed to get user, requeueing: %w", err).Error())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		logr.Error(err, "failed to get user")
+		return ctrl.Result{}, err
+	}
+	if apiUser == nil {
+		return ctrl.Result{}, nil
+	}
+
+	disabled := false
+	newUser := mailu.User{Email: address, ReplyEnabled: &disabled}
+	if user != nil && user.DeletionTimestamp == nil && user.Spec.Name+"@"+user.Spec.Domain == address {
+		if newUser, err = userReply(user); err != nil {
+			meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+			logr.Error(err, "failed to get reply of user from spec")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if observeOnly(reply, r.ObserveOnly) {
+		reportPlan(r.Recorder, reply, &reply.Status.Conditions, "Update", "Would reset auto-reply of user "+address+" in MailU")
+		logr.Info("observe-only, not resetting auto-reply")
+		return ctrl.Result{}, nil
+	}
+
+	retry, err = updateUser(ctx, r.ApiClient, newUser)
+	if err != nil {
+		meta.SetStatusCondition(&reply.Status.Conditions, getAutoReplyReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		if retry {
+			logr.Info(fmt.Errorf("failed to reset auto-reply, requeueing: %w", err).Error())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		logr.Error(err, "failed to reset auto-reply")
+		return ctrl.Result{}, err
+	}
+	if retry {
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	logr.Info("reset auto-reply")
+
+	return ctrl.Result{}, nil
+}
+
+// autoReplySchedule returns the index of the first window active at now, or -1 if none is, and the next time after
+// now at which a window starts or ends, or the zero time if there is none.
+func autoReplySchedule(windows []operatorv1alpha1.AutoReplyWindow, now time.Time) (int, time.Time) {
+	active := -1
+	var next time.Time
+	for i, w := range windows {
+		if active < 0 && !now.Before(w.Start.Time) && now.Before(w.End.Time) {
+			active = i
+		}
+		for _, t := range []time.Time{w.Start.Time, w.End.Time} {
+			if t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	return active, next
+}
+
+// autoReplyData is passed to the subject and body templates of an auto-reply.
+type autoReplyData struct {
+	// Name is the displayed name of the user, or its address if not set.
+	Name string
+	// Address is the e-mail address of the user.
+	Address string
+	// Start and End are the times of the active window.
+	Start time.Time
+	End   time.Time
+}
+
+// autoReplyUser returns the reply fields of the user in MailU for the active window, or with replies disabled and
+// the other reply fields as they are in MailU if no window is active.
+func autoReplyUser(reply *operatorv1alpha1.AutoReply, user *operatorv1alpha1.User, apiUser *mailu.User, active int) (mailu.User, error) {
+	address := user.Spec.Name + "@" + user.Spec.Domain
+	enabled := active >= 0
+	u := mailu.User{
+		Email:          address,
+		ReplyEnabled:   &enabled,
+		ReplySubject:   apiUser.ReplySubject,
+		ReplyBody:      apiUser.ReplyBody,
+		ReplyStartDate: apiUser.ReplyStartDate,
+		ReplyEndDate:   apiUser.ReplyEndDate,
+	}
+	if !enabled {
+		return u, nil
+	}
+
+	window := reply.Spec.Windows[active]
+	data := autoReplyData{Name: user.Spec.DisplayedName, Address: address, Start: window.Start.Time, End: window.End.Time}
+	if data.Name == "" {
+		data.Name = address
+	}
+	subject, err := renderAutoReply("subject", firstNonEmpty(window.Subject, reply.Spec.Subject), data)
+	if err != nil {
+		return u, err
+	}
+	body, err := renderAutoReply("body", firstNonEmpty(window.Body, reply.Spec.Body), data)
+	if err != nil {
+		return u, err
+	}
+
+	// MailU sends replies from the start date until the end date, both included, while the window ends before End
+	u.ReplySubject = &subject
+	u.ReplyBody = &body
+	u.ReplyStartDate = &openapitypes.Date{Time: window.Start.UTC()}
+	u.ReplyEndDate = &openapitypes.Date{Time: window.End.Add(-time.Nanosecond).UTC()}
+	return u, nil
+}
+
+// renderAutoReply executes the template text with the data of the active window.
+func renderAutoReply(name, text string, data autoReplyData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// userReply returns the reply fields of the user in MailU as set in the spec of the User resource.
+func userReply(user *operatorv1alpha1.User) (mailu.User, error) {
+	u := mailu.User{
+		Email:        user.Spec.Name + "@" + user.Spec.Domain,
+		ReplyEnabled: &user.Spec.ReplyEnabled,
+		ReplySubject: &user.Spec.ReplySubject,
+		ReplyBody:    &user.Spec.ReplyBody,
+	}
+	for _, d := range []struct {
+		date  string
+		field **openapitypes.Date
+	}{{user.Spec.ReplyStartDate, &u.ReplyStartDate}, {user.Spec.ReplyEndDate, &u.ReplyEndDate}} {
+		if d.date == "" {
+			continue
+		}
+		date := &openapitypes.Date{}
+		if err := date.UnmarshalText([]byte(d.date)); err != nil {
+			return mailu.User{}, err
+		}
+		*d.field = date
+	}
+	return u, nil
+}
+
+// managedByAutoReply returns true if the replies of the user are managed by an AutoReply.
+func managedByAutoReply(ctx context.Context, c client.Reader, user *operatorv1alpha1.User) (bool, error) {
+	replies := &operatorv1alpha1.AutoReplyList{}
+	if err := c.List(ctx, replies, client.MatchingFields{IndexAutoReplyUser: user.Namespace + "/" + user.Name}); err != nil {
+		return false, err
+	}
+	return len(replies.Items) > 0, nil
+}
+
+// enqueueUserAutoReplies returns a handler enqueueing the AutoReplies of the changed User, so they are applied once
+// it is ready.
+func enqueueUserAutoReplies(c client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(mapUserAutoReplies(c))
+}
+
+func mapUserAutoReplies(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		replies := &operatorv1alpha1.AutoReplyList{}
+		if err := c.List(ctx, replies, client.MatchingFields{IndexAutoReplyUser: obj.GetNamespace() + "/" + obj.GetName()}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list auto-replies")
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, r := range replies.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: r.Namespace, Name: r.Name},
+			})
+		}
+		return requests
+	}
+}
+
+func getAutoReplyReadyCondition(status metav1.ConditionStatus, reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    AutoReplyConditionTypeReady,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AutoReplyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&operatorv1alpha1.AutoReply{}).
+		Watches(&operatorv1alpha1.User{}, enqueueUserAutoReplies(mgr.GetClient())).
+		Watches(&operatorv1alpha1.AutoReply{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.AutoReplyList{})).
+		Complete(reconcile.AsReconciler(r.Client, r))
+}
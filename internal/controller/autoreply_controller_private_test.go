@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+func Test_autoReplySchedule(t *testing.T) {
+	at := func(day int) metav1.Time { return metav1.NewTime(time.Date(2024, 8, day, 0, 0, 0, 0, time.UTC)) }
+	windows := []operatorv1alpha1.AutoReplyWindow{
+		{Start: at(5), End: at(10)},
+		{Start: at(8), End: at(20)},
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		wantActive int
+		wantNext   time.Time
+	}{
+		{name: "before all windows", now: at(1).Time, wantActive: -1, wantNext: at(5).Time},
+		{name: "at the start of a window", now: at(5).Time, wantActive: 0, wantNext: at(8).Time},
+		{name: "overlapping windows", now: at(9).Time, wantActive: 0, wantNext: at(10).Time},
+		{name: "at the end of a window", now: at(10).Time, wantActive: 1, wantNext: at(20).Time},
+		{name: "after all windows", now: at(25).Time, wantActive: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, next := autoReplySchedule(windows, tt.now)
+			if active != tt.wantActive || !next.Equal(tt.wantNext) {
+				t.Errorf("autoReplySchedule() = %d, %v, want %d, %v", active, next, tt.wantActive, tt.wantNext)
+			}
+		})
+	}
+}
+
+func Test_autoReplyUser(t *testing.T) {
+	user := &operatorv1alpha1.User{Spec: operatorv1alpha1.UserSpec{Name: "john.doe", Domain: "example.com", DisplayedName: "John"}}
+	subject, body := "old subject", "old body"
+	apiUser := &mailu.User{Email: "john.doe@example.com", ReplySubject: &subject, ReplyBody: &body}
+	reply := &operatorv1alpha1.AutoReply{Spec: operatorv1alpha1.AutoReplySpec{
+		Subject: "Out of office",
+		Body:    `{{ .Name }} is back on {{ .End.Format "2006-01-02" }}`,
+		Windows: []operatorv1alpha1.AutoReplyWindow{{
+			Start:   metav1.NewTime(time.Date(2024, 8, 5, 0, 0, 0, 0, time.UTC)),
+			End:     metav1.NewTime(time.Date(2024, 8, 10, 0, 0, 0, 0, time.UTC)),
+			Subject: "Vacation of {{ .Address }}",
+		}},
+	}}
+
+	got, err := autoReplyUser(reply, user, apiUser, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !*got.ReplyEnabled || *got.ReplySubject != "Vacation of john.doe@example.com" || *got.ReplyBody != "John is back on 2024-08-10" {
+		t.Errorf("autoReplyUser() = %+v", got)
+	}
+	// MailU includes the end date, so the last day of the window is the day before its end at midnight
+	if got.ReplyStartDate.String() != "2024-08-05" || got.ReplyEndDate.String() != "2024-08-09" {
+		t.Errorf("autoReplyUser() dates = %s, %s", got.ReplyStartDate, got.ReplyEndDate)
+	}
+
+	got, err = autoReplyUser(reply, user, apiUser, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.ReplyEnabled || *got.ReplySubject != subject || *got.ReplyBody != body {
+		t.Errorf("autoReplyUser() without active window = %+v", got)
+	}
+
+	reply.Spec.Body = "{{ .Unknown }}"
+	if _, err := autoReplyUser(reply, user, apiUser, 0); err == nil {
+		t.Errorf("autoReplyUser() with invalid template did not fail")
+	}
+}
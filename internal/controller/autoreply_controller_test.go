@@ -0,0 +1,126 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	. "github.com/sickhub/mailu-operator/internal/controller"
+)
+
+var _ = Describe("AutoReply Controller", func() {
+	var (
+		controllerReconciler   *AutoReplyReconciler
+		res                    *operatorv1alpha1.AutoReply
+		resAfterReconciliation *operatorv1alpha1.AutoReply
+		user                   *operatorv1alpha1.User
+	)
+	ctx := context.Background()
+
+	reconcile := func(deleted bool) (ctrl.Result, error) {
+		Expect(res).NotTo(BeNil())
+		Expect(controllerReconciler).NotTo(BeNil())
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      res.GetName(),
+			Namespace: res.GetNamespace(),
+		}
+		result, resultErr := controllerReconciler.Reconcile(ctx, res)
+
+		resAfterReconciliation = &operatorv1alpha1.AutoReply{}
+		err := k8sClient.Get(ctx, typeNamespacedName, resAfterReconciliation)
+		if !deleted {
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		return result, resultErr
+	}
+
+	BeforeEach(func() {
+		mock = ghttp.NewServer()
+
+		Expect(k8sClient).NotTo(BeNil())
+		controllerReconciler = &AutoReplyReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+			ApiURL: mock.URL(),
+		}
+	})
+
+	Context("On an empty cluster", Ordered, func() {
+
+		When("creating an AutoReply with an active window", func() {
+			end := time.Now().Add(2 * time.Hour)
+
+			BeforeAll(func() {
+				user = CreateResource(operatorv1alpha1.User{}, "vacation", mockDomain).(*operatorv1alpha1.User)
+				Expect(k8sClient.Create(ctx, user)).To(Succeed())
+				meta.SetStatusCondition(&user.Status.Conditions, metav1.Condition{Type: UserConditionTypeReady, Status: metav1.ConditionTrue, Reason: "Created"})
+				Expect(k8sClient.Status().Update(ctx, user)).To(Succeed())
+
+				res = &operatorv1alpha1.AutoReply{
+					ObjectMeta: metav1.ObjectMeta{Name: "vacation", Namespace: "default"},
+					Spec: operatorv1alpha1.AutoReplySpec{
+						UserName: user.Name,
+						Subject:  "Out of office",
+						Body:     "{{ .Address }} is back on {{ .End.Format \"2006-01-02\" }}",
+						Windows: []operatorv1alpha1.AutoReplyWindow{{
+							Start: metav1.NewTime(time.Now().Add(-time.Hour)),
+							End:   metav1.NewTime(end),
+						}},
+					},
+				}
+				Expect(k8sClient.Create(ctx, res)).To(Succeed())
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)).To(Succeed())
+			})
+
+			It("enables the replies and requeues at the end of the window", func() {
+				prepareFindUser(user, http.StatusOK)
+				preparePatchUser(user, http.StatusOK)
+
+				result, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.RequeueAfter).To(BeNumerically("<=", 2*time.Hour))
+
+				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
+				ready := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, AutoReplyConditionTypeReady)
+				Expect(ready).ToNot(BeNil())
+				Expect(ready.Reason).To(Equal("Active"))
+				Expect(resAfterReconciliation.Status.ActiveWindow).To(HaveValue(Equal(0)))
+				Expect(resAfterReconciliation.Status.NextTransition).ToNot(BeNil())
+				Expect(resAfterReconciliation.Status.AppliedAddress).To(Equal("vacation@" + mockDomain))
+			})
+		})
+
+		When("deleting an AutoReply", func() {
+			BeforeAll(func() {
+				res = resAfterReconciliation.DeepCopy()
+				Expect(k8sClient.Delete(ctx, res)).To(Succeed())
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)).To(Succeed())
+			})
+
+			AfterAll(func() {
+				Expect(k8sClient.Delete(ctx, user)).To(Succeed())
+			})
+
+			It("applies the replies of the user spec again", func() {
+				prepareFindUser(user, http.StatusOK)
+				preparePatchUser(user, http.StatusOK)
+
+				_, err := reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.AutoReply{}))
+			})
+		})
+	})
+})
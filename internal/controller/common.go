@@ -1,5 +1,358 @@
 package controller
 
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
 const (
 	FinalizerName = "operator.mailu.io/finalizer"
+
+	// AnnotationResyncInterval overrides the global resync interval for a single resource, e.g. "10m".
+	AnnotationResyncInterval = "operator.mailu.io/resync-interval"
+	// AnnotationDriftPolicy overrides the global drift policy for a single resource.
+	AnnotationDriftPolicy = "operator.mailu.io/drift-policy"
+	// AnnotationObserveOnly overrides the global observe-only mode for a single resource, e.g. "true".
+	AnnotationObserveOnly = "operator.mailu.io/observe-only"
+	// AnnotationPaused pauses the reconciliation of a single resource, if set to "true".
+	AnnotationPaused = "operator.mailu.io/paused"
+
+	// DriftPolicyCorrect corrects changes made in MailU outside the operator.
+	DriftPolicyCorrect = "Correct"
+	// DriftPolicyReport only reports changes made in MailU outside the operator.
+	DriftPolicyReport = "Report"
+
+	ConditionTypeDrifted     = "Drifted"
+	ConditionTypeObserveOnly = "ObserveOnly"
+	ConditionTypePaused      = "Paused"
+	ConditionTypeAdopted     = "Adopted"
+)
+
+var (
+	// userFields maps the field names of UserSpec to the field names of the Mailu API.
+	userFields = map[string]string{
+		"allowSpoofing":      "allow_spoofing",
+		"changePassword":     "change_pw_next_login",
+		"comment":            "comment",
+		"displayedName":      "displayed_name",
+		"enabled":            "enabled",
+		"enableIMAP":         "enable_imap",
+		"enablePOP":          "enable_pop",
+		"forwardEnabled":     "forward_enabled",
+		"forwardDestination": "forward_destination",
+		"forwardKeep":        "forward_keep",
+		"globalAdmin":        "global_admin",
+		"quotaBytes":         "quota_bytes",
+		"replyEnabled":       "reply_enabled",
+		"replySubject":       "reply_subject",
+		"replyBody":          "reply_body",
+		"replyStartDate":     "reply_startdate",
+		"replyEndDate":       "reply_enddate",
+		"spamEnabled":        "spam_enabled",
+		"spamMarkAsRead":     "spam_mark_as_read",
+		"spamThreshold":      "spam_threshold",
+	}
+
+	// domainFields maps the field names of DomainSpec to the field names of the Mailu API.
+	domainFields = map[string]string{
+		"comment":       "comment",
+		"maxUsers":      "max_users",
+		"maxAliases":    "max_aliases",
+		"maxQuotaBytes": "max_quota_bytes",
+		"signupEnabled": "signup_enabled",
+		"alternatives":  "alternatives",
+	}
+
+	// ownerMarkerRegexp matches the ownership marker at the end of a comment in MailU.
+	ownerMarkerRegexp = regexp.MustCompile(`\s*\[mailu-operator (\S+)/(\S+) (\S+)\]$`)
+
+	// aliasFields maps the field names of AliasSpec to the field names of the Mailu API.
+	aliasFields = map[string]string{
+		"comment":     "comment",
+		"destination": "destination",
+		"wildcard":    "wildcard",
+	}
 )
+
+// ignoreFields returns a copy of desired in which all fields matching one of the patterns carry the value of current.
+// The patterns refer to the field names of the spec (e.g. 'spamThreshold' or 'reply*'), which are translated to
+// the Mailu API field names with the given fields map.
+func ignoreFields[T any](desired, current T, fields map[string]string, patterns []string) (T, error) {
+	var result T
+	if len(patterns) == 0 {
+		return desired, nil
+	}
+	// a malformed pattern would silently ignore nothing
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return result, fmt.Errorf("invalid ignoreFields pattern %q: %w", pattern, err)
+		}
+	}
+
+	desiredFields := map[string]json.RawMessage{}
+	currentFields := map[string]json.RawMessage{}
+	if err := remarshal(desired, &desiredFields); err != nil {
+		return result, err
+	}
+	if err := remarshal(current, &currentFields); err != nil {
+		return result, err
+	}
+
+	for specField, apiField := range fields {
+		if !matchesAny(specField, patterns) {
+			continue
+		}
+		if value, ok := currentFields[apiField]; ok {
+			desiredFields[apiField] = value
+		} else {
+			delete(desiredFields, apiField)
+		}
+	}
+
+	err := remarshal(desiredFields, &result)
+	return result, err
+}
+
+// matchesAny returns true if the name matches at least one of the patterns.
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remarshal converts in to out by marshalling it to JSON and back.
+func remarshal(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// diffFields returns the sorted spec field names whose values differ between desired and current.
+func diffFields[T any](desired, current T, fields map[string]string) []string {
+	desiredFields := map[string]json.RawMessage{}
+	currentFields := map[string]json.RawMessage{}
+	_ = remarshal(desired, &desiredFields) //nolint:errcheck
+	_ = remarshal(current, &currentFields) //nolint:errcheck
+
+	diff := []string{}
+	for specField, apiField := range fields {
+		if string(desiredFields[apiField]) != string(currentFields[apiField]) {
+			diff = append(diff, specField)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// resyncInterval returns the interval after which the resource should be compared with MailU again.
+// The annotation takes precedence over the given default, 0 disables the periodic resync.
+func resyncInterval(obj metav1.Object, defaultInterval time.Duration) time.Duration {
+	if val, ok := obj.GetAnnotations()[AnnotationResyncInterval]; ok {
+		if interval, err := time.ParseDuration(val); err == nil {
+			return interval
+		}
+	}
+	return defaultInterval
+}
+
+// requeueAt shortens the requeue of the result to the given time, if it is set and comes before the requeue.
+func requeueAt(result ctrl.Result, at, now time.Time) ctrl.Result {
+	if at.IsZero() {
+		return result
+	}
+	if until := at.Sub(now); result.RequeueAfter == 0 || until < result.RequeueAfter {
+		result.RequeueAfter = until
+	}
+	return result
+}
+
+// driftPolicy returns the policy to apply when the resource was changed in MailU.
+// The annotation takes precedence over the given default.
+func driftPolicy(obj metav1.Object, defaultPolicy string) string {
+	switch obj.GetAnnotations()[AnnotationDriftPolicy] {
+	case DriftPolicyCorrect:
+		return DriftPolicyCorrect
+	case DriftPolicyReport:
+		return DriftPolicyReport
+	}
+	if defaultPolicy == DriftPolicyReport {
+		return DriftPolicyReport
+	}
+	return DriftPolicyCorrect
+}
+
+// recordEvent emits an event for the object, if a recorder is configured.
+func recordEvent(recorder events.EventRecorder, obj runtime.Object, eventtype, reason, action, note string, args ...interface{}) {
+	if recorder == nil {
+		return
+	}
+	recorder.Eventf(obj, nil, eventtype, reason, action, note, args...)
+}
+
+func getDriftedCondition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionTypeDrifted,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Drifted",
+		Message: message,
+	}
+}
+
+// deletionPolicy returns the deletion policy of the resource, or the given default if it is not set.
+func deletionPolicy(policy, defaultPolicy operatorv1alpha1.DeletionPolicy) operatorv1alpha1.DeletionPolicy {
+	if policy == "" {
+		policy = defaultPolicy
+	}
+	if policy == operatorv1alpha1.DeletionPolicyRetain {
+		return operatorv1alpha1.DeletionPolicyRetain
+	}
+	return operatorv1alpha1.DeletionPolicyDelete
+}
+
+// ownerMarker identifies the resource which manages an object in MailU.
+type ownerMarker struct {
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// withOwnerMarker appends the ownership marker of the resource to the comment, replacing an existing marker.
+// The marker is a structured suffix: 'comment [mailu-operator namespace/name uid]'.
+func withOwnerMarker(comment string, obj metav1.Object) string {
+	marker := fmt.Sprintf("[mailu-operator %s/%s %s]", obj.GetNamespace(), obj.GetName(), obj.GetUID())
+	comment = stripOwnerMarker(comment)
+	if comment == "" {
+		return marker
+	}
+	return comment + " " + marker
+}
+
+// stripOwnerMarker returns the comment without the ownership marker.
+func stripOwnerMarker(comment string) string {
+	return ownerMarkerRegexp.ReplaceAllString(comment, "")
+}
+
+// parseOwnerMarker returns the ownership marker of the comment, if it contains one.
+func parseOwnerMarker(comment *string) (ownerMarker, bool) {
+	if comment == nil {
+		return ownerMarker{}, false
+	}
+	match := ownerMarkerRegexp.FindStringSubmatch(*comment)
+	if match == nil {
+		return ownerMarker{}, false
+	}
+	return ownerMarker{Namespace: match[1], Name: match[2], UID: match[3]}, true
+}
+
+// ownedBy returns true if the comment contains the ownership marker of the resource.
+func ownedBy(comment *string, obj metav1.Object) bool {
+	marker, ok := parseOwnerMarker(comment)
+	return ok && marker.UID == string(obj.GetUID())
+}
+
+// withoutOwnerMarkerDiff removes 'comment' from the differing fields, if the comments only differ in the marker.
+func withoutOwnerMarkerDiff(diff []string, desired, current *string) []string {
+	var desiredComment, currentComment string
+	if desired != nil {
+		desiredComment = *desired
+	}
+	if current != nil {
+		currentComment = *current
+	}
+	if stripOwnerMarker(desiredComment) != stripOwnerMarker(currentComment) {
+		return diff
+	}
+
+	result := []string{}
+	for _, field := range diff {
+		if field != "comment" {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// checkAdoption returns the reason and message why an existing object of the given kind must not be adopted
+// according to the policy and the differing fields. Both are empty, if the object can be adopted.
+func checkAdoption(policy operatorv1alpha1.AdoptionPolicy, kind string, diff []string) (string, string) {
+	switch policy {
+	case operatorv1alpha1.AdoptionPolicyFailIfExists:
+		return "AlreadyExists", kind + " already exists in MailU"
+	case operatorv1alpha1.AdoptionPolicyAdoptIfMatching:
+		if len(diff) > 0 {
+			return "AdoptionMismatch", kind + " already exists in MailU and differs: " + strings.Join(diff, ", ")
+		}
+	}
+	return "", ""
+}
+
+// observeOnly returns true if changes to MailU must only be planned, but not executed.
+// The annotation takes precedence over the given default.
+func observeOnly(obj metav1.Object, defaultObserveOnly bool) bool {
+	if val, ok := obj.GetAnnotations()[AnnotationObserveOnly]; ok {
+		if observe, err := strconv.ParseBool(val); err == nil {
+			return observe
+		}
+	}
+	return defaultObserveOnly
+}
+
+// paused returns true if the reconciliation of the resource is paused.
+func paused(obj metav1.Object) bool {
+	pause, err := strconv.ParseBool(obj.GetAnnotations()[AnnotationPaused])
+	return err == nil && pause
+}
+
+// reportPlan records the action that would be executed in MailU in the status and as an event.
+func reportPlan(recorder events.EventRecorder, obj runtime.Object, conditions *[]metav1.Condition, action, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    ConditionTypeObserveOnly,
+		Status:  metav1.ConditionTrue,
+		Reason:  action,
+		Message: message,
+	})
+	recordEvent(recorder, obj, corev1.EventTypeNormal, "Planned", action, "%s", message)
+}
+
+func getPausedCondition() metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionTypePaused,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Paused",
+		Message: "Reconciliation is paused by annotation " + AnnotationPaused,
+	}
+}
+
+func getAdoptedCondition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionTypeAdopted,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Adopted",
+		Message: message,
+	}
+}
+
+// renamed returns true if the address differs from the address that was last applied to MailU.
+func renamed(applied, address string) bool {
+	return applied != "" && applied != address
+}
@@ -0,0 +1,440 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+func Test_ignoreFields(t *testing.T) {
+	desiredComment := "desired"
+	currentComment := "current"
+	desiredReply := true
+	currentReply := false
+	desiredThreshold := 80
+	currentThreshold := 50
+	currentSubject := "out of office"
+
+	desired := mailu.User{
+		Email:         "test@example.com",
+		Comment:       &desiredComment,
+		ReplyEnabled:  &desiredReply,
+		SpamThreshold: &desiredThreshold,
+	}
+	current := mailu.User{
+		Email:         "test@example.com",
+		Comment:       &currentComment,
+		ReplyEnabled:  &currentReply,
+		ReplySubject:  &currentSubject,
+		SpamThreshold: &currentThreshold,
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     mailu.User
+	}{
+		{
+			name:     "no ignored fields",
+			patterns: nil,
+			want:     desired,
+		},
+		{
+			name:     "ignore single field",
+			patterns: []string{"spamThreshold"},
+			want: mailu.User{
+				Email:         "test@example.com",
+				Comment:       &desiredComment,
+				ReplyEnabled:  &desiredReply,
+				SpamThreshold: &currentThreshold,
+			},
+		},
+		{
+			name:     "ignore fields by wildcard",
+			patterns: []string{"reply*", "comment"},
+			want: mailu.User{
+				Email:         "test@example.com",
+				Comment:       &currentComment,
+				ReplyEnabled:  &currentReply,
+				ReplySubject:  &currentSubject,
+				SpamThreshold: &desiredThreshold,
+			},
+		},
+		{
+			name:     "ignore unknown patterns",
+			patterns: []string{"unknown"},
+			want:     desired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ignoreFields(desired, current, userFields, tt.patterns)
+			if err != nil {
+				t.Fatalf("ignoreFields() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ignoreFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ignoreFields(desired, current, userFields, []string{"comment", "reply["}); err == nil {
+		t.Error("ignoreFields() with a malformed pattern did not fail")
+	}
+}
+
+func Test_diffFields(t *testing.T) {
+	desiredComment := "desired"
+	currentComment := "current"
+	threshold := 80
+
+	desired := mailu.User{Email: "test@example.com", Comment: &desiredComment, SpamThreshold: &threshold}
+	current := mailu.User{Email: "test@example.com", Comment: &currentComment}
+
+	got := diffFields(desired, current, userFields)
+	want := []string{"comment", "spamThreshold"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffFields() = %v, want %v", got, want)
+	}
+
+	got = diffFields(desired, desired, userFields)
+	if len(got) != 0 {
+		t.Errorf("diffFields() = %v, want no difference", got)
+	}
+}
+
+func Test_resyncInterval(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        time.Duration
+	}{
+		{
+			name: "default interval",
+			want: time.Hour,
+		},
+		{
+			name:        "interval from annotation",
+			annotations: map[string]string{AnnotationResyncInterval: "5m"},
+			want:        5 * time.Minute,
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{AnnotationResyncInterval: "often"},
+			want:        time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &metav1.ObjectMeta{Annotations: tt.annotations}
+			if got := resyncInterval(obj, time.Hour); got != tt.want {
+				t.Errorf("resyncInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_requeueAt(t *testing.T) {
+	now := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		result ctrl.Result
+		at     time.Time
+		want   time.Duration
+	}{
+		{name: "no time", result: ctrl.Result{RequeueAfter: time.Hour}, want: time.Hour},
+		{name: "before the requeue", result: ctrl.Result{RequeueAfter: time.Hour}, at: now.Add(time.Minute), want: time.Minute},
+		{name: "after the requeue", result: ctrl.Result{RequeueAfter: time.Hour}, at: now.Add(2 * time.Hour), want: time.Hour},
+		{name: "without requeue", at: now.Add(2 * time.Hour), want: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requeueAt(tt.result, tt.at, now); got.RequeueAfter != tt.want {
+				t.Errorf("requeueAt() = %v, want %v", got.RequeueAfter, tt.want)
+			}
+		})
+	}
+}
+
+func Test_driftPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		defaultPolicy string
+		want          string
+	}{
+		{
+			name: "empty default",
+			want: DriftPolicyCorrect,
+		},
+		{
+			name:          "default policy",
+			defaultPolicy: DriftPolicyReport,
+			want:          DriftPolicyReport,
+		},
+		{
+			name:          "policy from annotation",
+			annotations:   map[string]string{AnnotationDriftPolicy: DriftPolicyCorrect},
+			defaultPolicy: DriftPolicyReport,
+			want:          DriftPolicyCorrect,
+		},
+		{
+			name:          "invalid annotation",
+			annotations:   map[string]string{AnnotationDriftPolicy: "Ignore"},
+			defaultPolicy: DriftPolicyReport,
+			want:          DriftPolicyReport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &metav1.ObjectMeta{Annotations: tt.annotations}
+			if got := driftPolicy(obj, tt.defaultPolicy); got != tt.want {
+				t.Errorf("driftPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reportPlan(t *testing.T) {
+	recorder := events.NewFakeRecorder(1)
+	alias := &operatorv1alpha1.Alias{}
+
+	reportPlan(recorder, alias, &alias.Status.Conditions, "Create", "Would create alias %@example.com in MailU")
+
+	want := "Normal Planned Would create alias %@example.com in MailU"
+	if got := <-recorder.Events; got != want {
+		t.Errorf("reportPlan() event = %q, want %q", got, want)
+	}
+}
+
+func Test_paused(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "no annotation",
+			want: false,
+		},
+		{
+			name:        "paused",
+			annotations: map[string]string{AnnotationPaused: "true"},
+			want:        true,
+		},
+		{
+			name:        "not paused",
+			annotations: map[string]string{AnnotationPaused: "false"},
+			want:        false,
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{AnnotationPaused: "yes please"},
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &metav1.ObjectMeta{Annotations: tt.annotations}
+			if got := paused(obj); got != tt.want {
+				t.Errorf("paused() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_deletionPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        operatorv1alpha1.DeletionPolicy
+		defaultPolicy operatorv1alpha1.DeletionPolicy
+		want          operatorv1alpha1.DeletionPolicy
+	}{
+		{
+			name: "empty default",
+			want: operatorv1alpha1.DeletionPolicyDelete,
+		},
+		{
+			name:          "default policy",
+			defaultPolicy: operatorv1alpha1.DeletionPolicyRetain,
+			want:          operatorv1alpha1.DeletionPolicyRetain,
+		},
+		{
+			name:          "policy of the resource",
+			policy:        operatorv1alpha1.DeletionPolicyDelete,
+			defaultPolicy: operatorv1alpha1.DeletionPolicyRetain,
+			want:          operatorv1alpha1.DeletionPolicyDelete,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deletionPolicy(tt.policy, tt.defaultPolicy); got != tt.want {
+				t.Errorf("deletionPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkAdoption(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     operatorv1alpha1.AdoptionPolicy
+		diff       []string
+		wantReason string
+	}{
+		{
+			name:       "adopt",
+			policy:     operatorv1alpha1.AdoptionPolicyAdopt,
+			diff:       []string{"comment"},
+			wantReason: "",
+		},
+		{
+			name:       "empty policy adopts",
+			diff:       []string{"comment"},
+			wantReason: "",
+		},
+		{
+			name:       "fail if exists",
+			policy:     operatorv1alpha1.AdoptionPolicyFailIfExists,
+			wantReason: "AlreadyExists",
+		},
+		{
+			name:       "adopt if matching",
+			policy:     operatorv1alpha1.AdoptionPolicyAdoptIfMatching,
+			wantReason: "",
+		},
+		{
+			name:       "adopt if matching with difference",
+			policy:     operatorv1alpha1.AdoptionPolicyAdoptIfMatching,
+			diff:       []string{"comment"},
+			wantReason: "AdoptionMismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := checkAdoption(tt.policy, "User", tt.diff); got != tt.wantReason {
+				t.Errorf("checkAdoption() = %v, want %v", got, tt.wantReason)
+			}
+		})
+	}
+}
+
+func Test_withOwnerMarker(t *testing.T) {
+	obj := &metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "1234"}
+
+	tests := []struct {
+		name    string
+		comment string
+		want    string
+	}{
+		{
+			name:    "empty comment",
+			comment: "",
+			want:    "[mailu-operator default/foo 1234]",
+		},
+		{
+			name:    "comment",
+			comment: "some comment",
+			want:    "some comment [mailu-operator default/foo 1234]",
+		},
+		{
+			name:    "replaces existing marker",
+			comment: "some comment [mailu-operator other/bar 5678]",
+			want:    "some comment [mailu-operator default/foo 1234]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withOwnerMarker(tt.comment, obj)
+			if got != tt.want {
+				t.Errorf("withOwnerMarker() = %v, want %v", got, tt.want)
+			}
+			if stripped := stripOwnerMarker(got); stripped != stripOwnerMarker(tt.comment) {
+				t.Errorf("stripOwnerMarker() = %v, want %v", stripped, stripOwnerMarker(tt.comment))
+			}
+		})
+	}
+}
+
+func Test_parseOwnerMarker(t *testing.T) {
+	comment := "some comment [mailu-operator default/foo 1234]"
+	plain := "some comment"
+
+	tests := []struct {
+		name    string
+		comment *string
+		want    ownerMarker
+		wantOk  bool
+	}{
+		{
+			name:    "nil comment",
+			comment: nil,
+		},
+		{
+			name:    "without marker",
+			comment: &plain,
+		},
+		{
+			name:    "with marker",
+			comment: &comment,
+			want:    ownerMarker{Namespace: "default", Name: "foo", UID: "1234"},
+			wantOk:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseOwnerMarker(tt.comment)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("parseOwnerMarker() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	if !ownedBy(&comment, &metav1.ObjectMeta{UID: "1234"}) {
+		t.Errorf("ownedBy() = false, want true")
+	}
+	if ownedBy(&comment, &metav1.ObjectMeta{UID: "5678"}) {
+		t.Errorf("ownedBy() = true, want false")
+	}
+}
+
+func Test_withoutOwnerMarkerDiff(t *testing.T) {
+	marked := "some comment [mailu-operator default/foo 1234]"
+	plain := "some comment"
+	other := "other comment"
+
+	tests := []struct {
+		name    string
+		desired *string
+		current *string
+		want    []string
+	}{
+		{
+			name:    "only the marker differs",
+			desired: &marked,
+			current: &plain,
+			want:    []string{"enabled"},
+		},
+		{
+			name:    "comment differs",
+			desired: &marked,
+			current: &other,
+			want:    []string{"comment", "enabled"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withoutOwnerMarkerDiff([]string{"comment", "enabled"}, tt.desired, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withoutOwnerMarkerDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
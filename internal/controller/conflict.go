@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+const (
+	// IndexEmail is the field index of Users and Aliases on their e-mail address in MailU.
+	IndexEmail = "spec.email"
+	// IndexDomainName is the field index of Domains on their name in MailU.
+	IndexDomainName = "spec.name"
+
+	ConditionTypeConflict = "Conflict"
+)
+
+// SetupIndexes registers the field indexes used to detect resources targeting the same object in MailU,
+// to find the users and aliases of a domain, the aliases referencing a user or alias, the users of a class and
+// the auto-replies of a user.
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, obj := range []client.Object{&operatorv1alpha1.User{}, &operatorv1alpha1.Alias{}, &operatorv1alpha1.MailingList{}, &operatorv1alpha1.Domain{}, &operatorv1alpha1.AutoReply{}} {
+		field, _ := indexValue(obj)
+		if err := indexer.IndexField(ctx, obj, field, func(o client.Object) []string {
+			_, value := indexValue(o)
+			return []string{value}
+		}); err != nil {
+			return err
+		}
+	}
+	for _, obj := range []client.Object{&operatorv1alpha1.User{}, &operatorv1alpha1.Alias{}, &operatorv1alpha1.MailingList{}} {
+		if err := indexer.IndexField(ctx, obj, IndexDomain, func(o client.Object) []string {
+			return []string{domainOf(o)}
+		}); err != nil {
+			return err
+		}
+	}
+	if err := indexer.IndexField(ctx, &operatorv1alpha1.Alias{}, IndexDestinationRefs, func(o client.Object) []string {
+		return destinationRefKeys(o.(*operatorv1alpha1.Alias))
+	}); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &operatorv1alpha1.User{}, IndexUserClassName, func(o client.Object) []string {
+		return []string{o.(*operatorv1alpha1.User).Spec.UserClassName}
+	})
+}
+
+// indexValue returns the field index and the name of the object in MailU targeted by the resource.
+// For an AutoReply, it is the User whose replies it manages.
+func indexValue(obj client.Object) (string, string) {
+	switch o := obj.(type) {
+	case *operatorv1alpha1.User:
+		return IndexEmail, o.Spec.Name + "@" + o.Spec.Domain
+	case *operatorv1alpha1.Alias:
+		return IndexEmail, o.Spec.Name + "@" + o.Spec.Domain
+	case *operatorv1alpha1.MailingList:
+		return IndexEmail, o.Spec.Name + "@" + o.Spec.Domain
+	case *operatorv1alpha1.Domain:
+		return IndexDomainName, o.Spec.Name
+	case *operatorv1alpha1.AutoReply:
+		return IndexAutoReplyUser, o.Namespace + "/" + o.Spec.UserName
+	}
+	return "", ""
+}
+
+// conflictLists returns empty lists of all kinds that may target the same object in MailU as the resource.
+func conflictLists(obj client.Object) map[string]client.ObjectList {
+	switch obj.(type) {
+	case *operatorv1alpha1.Domain:
+		return map[string]client.ObjectList{"Domain": &operatorv1alpha1.DomainList{}}
+	case *operatorv1alpha1.AutoReply:
+		return map[string]client.ObjectList{"AutoReply": &operatorv1alpha1.AutoReplyList{}}
+	}
+	return map[string]client.ObjectList{
+		"User":        &operatorv1alpha1.UserList{},
+		"Alias":       &operatorv1alpha1.AliasList{},
+		"MailingList": &operatorv1alpha1.MailingListList{},
+	}
+}
+
+type conflictCandidate struct {
+	kind string
+	obj  client.Object
+}
+
+// findConflictWinner returns the oldest resource targeting the same object in MailU as obj, e.g. 'User default/foo',
+// or an empty string if obj is the oldest one.
+func findConflictWinner(ctx context.Context, c client.Reader, kind string, obj client.Object) (string, error) {
+	field, value := indexValue(obj)
+
+	candidates := []conflictCandidate{{kind: kind, obj: obj}}
+	for k, list := range conflictLists(obj) {
+		if err := c.List(ctx, list, client.MatchingFields{field: value}); err != nil {
+			return "", err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range items {
+			o, ok := item.(client.Object)
+			if !ok || o.GetUID() == obj.GetUID() {
+				continue
+			}
+			candidates = append(candidates, conflictCandidate{kind: k, obj: o})
+		}
+	}
+
+	winner := conflictWinner(candidates)
+	if winner.obj.GetUID() == obj.GetUID() {
+		return "", nil
+	}
+	return fmt.Sprintf("%s %s/%s", winner.kind, winner.obj.GetNamespace(), winner.obj.GetName()), nil
+}
+
+// conflictWinner returns the candidate managing the object in MailU: the oldest resource wins, ties are broken by
+// kind, namespace and name.
+func conflictWinner(candidates []conflictCandidate) conflictCandidate {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		ta, tb := a.obj.GetCreationTimestamp(), b.obj.GetCreationTimestamp()
+		if !ta.Equal(&tb) {
+			return ta.Before(&tb)
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.obj.GetNamespace() != b.obj.GetNamespace() {
+			return a.obj.GetNamespace() < b.obj.GetNamespace()
+		}
+		return a.obj.GetName() < b.obj.GetName()
+	})
+	return candidates[0]
+}
+
+// managingDomain returns the Domain resource managing the domain in MailU, which is the winner of conflicting
+// resources regardless of its condition, or nil if there is none.
+func managingDomain(ctx context.Context, c client.Reader, name string) (*operatorv1alpha1.Domain, error) {
+	domains := &operatorv1alpha1.DomainList{}
+	if err := c.List(ctx, domains, client.MatchingFields{IndexDomainName: name}); err != nil {
+		return nil, err
+	}
+	if len(domains.Items) == 0 {
+		return nil, nil
+	}
+	candidates := []conflictCandidate{}
+	for i := range domains.Items {
+		candidates = append(candidates, conflictCandidate{kind: "Domain", obj: &domains.Items[i]})
+	}
+	return conflictWinner(candidates).obj.(*operatorv1alpha1.Domain), nil
+}
+
+// enqueueConflicting returns a handler enqueueing all resources of the list's kind that target the same object in
+// MailU as the changed resource, so they can take over once the winner is gone.
+func enqueueConflicting(c client.Reader, list client.ObjectList) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		field, value := indexValue(obj)
+		l := list.DeepCopyObject().(client.ObjectList)
+		if err := c.List(ctx, l, client.MatchingFields{field: value}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list conflicting resources")
+			return nil
+		}
+		items, err := meta.ExtractList(l)
+		if err != nil {
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, item := range items {
+			o, ok := item.(client.Object)
+			if !ok || o.GetUID() == obj.GetUID() {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()},
+			})
+		}
+		return requests
+	})
+}
+
+func getConflictCondition(msg string) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionTypeConflict,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Conflict",
+		Message: msg,
+	}
+}
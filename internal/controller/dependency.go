@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+const (
+	// IndexDomain is the field index of Users, Aliases and MailingLists on the domain they belong to.
+	IndexDomain = "spec.domain"
+
+	ConditionTypeDomainNotReady = "DomainNotReady"
+
+	// defaultDependencyCheckInterval is the interval to check for a domain or its users in MailU, if resyncing is
+	// disabled.
+	defaultDependencyCheckInterval = time.Minute
+)
+
+// domainOf returns the domain a User, Alias or MailingList belongs to.
+func domainOf(obj client.Object) string {
+	switch o := obj.(type) {
+	case *operatorv1alpha1.User:
+		return o.Spec.Domain
+	case *operatorv1alpha1.Alias:
+		return o.Spec.Domain
+	case *operatorv1alpha1.MailingList:
+		return o.Spec.Domain
+	}
+	return ""
+}
+
+// waitForDomain sets the DomainNotReady condition and returns true, if the domain of obj is not ready yet for users
+// and aliases to be created in it. The Domain resource managing the domain must be ready, obj is enqueued again once
+// it is.
+// Without a Domain resource, the domain must exist in MailU and obj is requeued until it does.
+func waitForDomain(ctx context.Context, c client.Reader, api *mailu.Client, obj client.Object, conditions *[]metav1.Condition, readyType string, defaultInterval time.Duration) (ctrl.Result, bool) {
+	logr := log.FromContext(ctx)
+	domain := domainOf(obj)
+
+	var msg string
+	var result ctrl.Result
+	managing, err := managingDomain(ctx, c, domain)
+	if err != nil {
+		logr.Info(fmt.Errorf("failed to list domains, requeueing: %w", err).Error())
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, true
+	}
+	if managing != nil {
+		// a conflicting Domain resource is never ready, only the one managing the domain counts
+		if !meta.IsStatusConditionTrue(managing.Status.Conditions, DomainConditionTypeReady) {
+			msg = fmt.Sprintf("Domain %s/%s is not ready", managing.Namespace, managing.Name)
+		}
+	} else {
+		exists, retry, err := domainExists(ctx, api, domain)
+		if err != nil {
+			if retry {
+				logr.Info(fmt.Errorf("failed to get domain, requeueing: %w", err).Error())
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, true
+			}
+			meta.SetStatusCondition(conditions, metav1.Condition{Type: readyType, Status: metav1.ConditionFalse, Reason: "Error", Message: err.Error()})
+			logr.Error(err, "failed to get domain")
+			return ctrl.Result{}, true
+		}
+		if !exists {
+			msg = fmt.Sprintf("Domain %s does not exist in MailU", domain)
+			result = ctrl.Result{RequeueAfter: dependencyCheckInterval(obj, defaultInterval)}
+		}
+	}
+
+	if msg == "" {
+		meta.RemoveStatusCondition(conditions, ConditionTypeDomainNotReady)
+		return ctrl.Result{}, false
+	}
+	meta.SetStatusCondition(conditions, getDomainNotReadyCondition(msg))
+	meta.SetStatusCondition(conditions, metav1.Condition{Type: readyType, Status: metav1.ConditionFalse, Reason: "DomainNotReady", Message: msg})
+	logr.Info("waiting for domain: " + msg)
+	return result, true
+}
+
+// dependencyCheckInterval returns the interval to check for dependencies again: the resync interval of the resource,
+// if resyncing is enabled.
+func dependencyCheckInterval(obj client.Object, defaultInterval time.Duration) time.Duration {
+	if interval := resyncInterval(obj, defaultInterval); interval > 0 {
+		return interval
+	}
+	return defaultDependencyCheckInterval
+}
+
+// listDependents returns the User, Alias and MailingList resources of the domain.
+func listDependents(ctx context.Context, c client.Reader, domain string) ([]client.Object, error) {
+	users := &operatorv1alpha1.UserList{}
+	if err := c.List(ctx, users, client.MatchingFields{IndexDomain: domain}); err != nil {
+		return nil, err
+	}
+	aliases := &operatorv1alpha1.AliasList{}
+	if err := c.List(ctx, aliases, client.MatchingFields{IndexDomain: domain}); err != nil {
+		return nil, err
+	}
+
+	lists := &operatorv1alpha1.MailingListList{}
+	if err := c.List(ctx, lists, client.MatchingFields{IndexDomain: domain}); err != nil {
+		return nil, err
+	}
+
+	objs := []client.Object{}
+	for i := range users.Items {
+		objs = append(objs, &users.Items[i])
+	}
+	for i := range aliases.Items {
+		objs = append(objs, &aliases.Items[i])
+	}
+	for i := range lists.Items {
+		objs = append(objs, &lists.Items[i])
+	}
+	return objs, nil
+}
+
+// summarize joins the first items up to the limit and counts the others.
+func summarize(items []string, limit int) string {
+	if len(items) <= limit {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:limit], ", "), len(items)-limit)
+}
+
+func domainExists(ctx context.Context, api *mailu.Client, domain string) (bool, bool, error) {
+	found, err := api.FindDomain(ctx, domain)
+	if err != nil {
+		return false, false, err
+	}
+	defer found.Body.Close() //nolint:errcheck
+
+	_, err = io.ReadAll(found.Body)
+	if err != nil {
+		return false, true, err
+	}
+
+	switch found.StatusCode {
+	case http.StatusOK:
+		return true, false, nil
+	case http.StatusNotFound:
+		return false, false, nil
+	case http.StatusBadRequest:
+		return false, false, errors.New("bad request")
+	case http.StatusBadGateway:
+		fallthrough
+	case http.StatusGatewayTimeout:
+		return false, true, errors.New("gateway timeout")
+	case http.StatusServiceUnavailable:
+		return false, true, errors.New("service unavailable")
+	}
+	return false, false, errors.New("unknown status: " + strconv.Itoa(found.StatusCode))
+}
+
+// enqueueWaitingForDomain returns a handler enqueueing all resources of the list's kind that wait for the changed
+// Domain, once it is ready.
+func enqueueWaitingForDomain(c client.Reader, list client.ObjectList) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(mapWaitingForDomain(c, list))
+}
+
+func mapWaitingForDomain(c client.Reader, list client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		domain, ok := obj.(*operatorv1alpha1.Domain)
+		if !ok || !meta.IsStatusConditionTrue(domain.Status.Conditions, DomainConditionTypeReady) {
+			return nil
+		}
+
+		l := list.DeepCopyObject().(client.ObjectList)
+		if err := c.List(ctx, l, client.MatchingFields{IndexDomain: domain.Spec.Name}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list resources waiting for domain")
+			return nil
+		}
+		items, err := meta.ExtractList(l)
+		if err != nil {
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, item := range items {
+			o, ok := item.(client.Object)
+			if !ok || !meta.IsStatusConditionTrue(conditionsOf(o), ConditionTypeDomainNotReady) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()},
+			})
+		}
+		return requests
+	}
+}
+
+// enqueueDeletingDomain returns a handler enqueueing the deleted Domain resources of the changed User or Alias, so
+// their deletion continues as soon as their users and aliases are gone.
+func enqueueDeletingDomain(c client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		domains := &operatorv1alpha1.DomainList{}
+		if err := c.List(ctx, domains, client.MatchingFields{IndexDomainName: domainOf(obj)}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list domains")
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, d := range domains.Items {
+			if d.DeletionTimestamp == nil {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: d.Namespace, Name: d.Name},
+			})
+		}
+		return requests
+	})
+}
+
+// conditionsOf returns the status conditions of a User, Alias or MailingList.
+func conditionsOf(obj client.Object) []metav1.Condition {
+	switch o := obj.(type) {
+	case *operatorv1alpha1.User:
+		return o.Status.Conditions
+	case *operatorv1alpha1.Alias:
+		return o.Status.Conditions
+	case *operatorv1alpha1.MailingList:
+		return o.Status.Conditions
+	}
+	return nil
+}
+
+func getDomainNotReadyCondition(msg string) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionTypeDomainNotReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  "DomainNotReady",
+		Message: msg,
+	}
+}
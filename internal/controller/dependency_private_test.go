@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func Test_mapWaitingForDomain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	waiting := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "waiting", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "waiting", Domain: "example.com"},
+	}
+	meta.SetStatusCondition(&waiting.Status.Conditions, getDomainNotReadyCondition("Domain default/example is not ready"))
+	ready := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "ready", Domain: "example.com"},
+	}
+	other := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "other", Domain: "example.org"},
+	}
+	meta.SetStatusCondition(&other.Status.Conditions, getDomainNotReadyCondition("Domain example.org does not exist in MailU"))
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(waiting, ready, other).
+		WithIndex(&operatorv1alpha1.User{}, IndexDomain, func(obj client.Object) []string {
+			return []string{domainOf(obj)}
+		}).Build()
+	mapFunc := mapWaitingForDomain(c, &operatorv1alpha1.UserList{})
+
+	domain := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec:       operatorv1alpha1.DomainSpec{Name: "example.com"},
+	}
+	if got := mapFunc(context.Background(), domain); len(got) != 0 {
+		t.Errorf("mapWaitingForDomain() = %v for a domain that is not ready", got)
+	}
+
+	meta.SetStatusCondition(&domain.Status.Conditions, metav1.Condition{Type: DomainConditionTypeReady, Status: metav1.ConditionTrue, Reason: "Created"})
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "waiting"}}}
+	if got := mapFunc(context.Background(), domain); !reflect.DeepEqual(got, want) {
+		t.Errorf("mapWaitingForDomain() = %v, want %v", got, want)
+	}
+}
+
+func Test_waitForDomain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	managing := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default", CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))},
+		Spec:       operatorv1alpha1.DomainSpec{Name: "example.com"},
+	}
+	meta.SetStatusCondition(&managing.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Error", "MailU is not available"))
+	// a conflicting duplicate does not make the domain ready
+	duplicate := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "duplicate", Namespace: "other", CreationTimestamp: metav1.Now()},
+		Spec:       operatorv1alpha1.DomainSpec{Name: "example.com"},
+	}
+	meta.SetStatusCondition(&duplicate.Status.Conditions, getDomainReadyCondition(metav1.ConditionTrue, "Created", "Domain created in MailU"))
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(managing, duplicate).
+		WithIndex(&operatorv1alpha1.Domain{}, IndexDomainName, func(obj client.Object) []string {
+			return []string{obj.(*operatorv1alpha1.Domain).Spec.Name}
+		}).Build()
+
+	user := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "john.doe", Domain: "example.com"},
+	}
+	if _, wait := waitForDomain(context.Background(), c, nil, user, &user.Status.Conditions, UserConditionTypeReady, 0); !wait {
+		t.Error("waitForDomain() did not wait for the managing Domain")
+	}
+	want := "Domain default/example is not ready"
+	if got := meta.FindStatusCondition(user.Status.Conditions, ConditionTypeDomainNotReady); got == nil || got.Message != want {
+		t.Errorf("waitForDomain() condition = %v, want message %q", got, want)
+	}
+}
+
+func Test_summarize(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	if got := summarize(items, 3); got != "a, b, c" {
+		t.Errorf("summarize() = %q", got)
+	}
+	if got := summarize(items, 2); got != "a, b and 1 more" {
+		t.Errorf("summarize() = %q", got)
+	}
+}
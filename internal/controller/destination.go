@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+const (
+	// IndexDestinationRefs is the field index of Aliases on the User and Alias resources they reference as destinations.
+	IndexDestinationRefs = "spec.destinationRefs"
+
+	ConditionTypeDestinationsResolved = "DestinationsResolved"
+	ConditionTypeDegraded             = "Degraded"
+)
+
+// aliasDestinations are the destinations of an alias in MailU.
+type aliasDestinations struct {
+	// all are the destinations of the spec followed by the addresses resolved from the references
+	all []string
+	// refs are the addresses resolved from the references
+	refs []string
+	// unresolved describes the references that could not be resolved
+	unresolved []string
+}
+
+// destinationRefKey returns the index value of a reference, e.g. 'User/default/foo'.
+func destinationRefKey(kind, namespace, name string) string {
+	if kind == "" {
+		kind = "User"
+	}
+	return kind + "/" + namespace + "/" + name
+}
+
+// destinationRefKeys returns the index values of all references of the alias.
+func destinationRefKeys(alias *operatorv1alpha1.Alias) []string {
+	keys := []string{}
+	for _, ref := range alias.Spec.DestinationRefs {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = alias.Namespace
+		}
+		keys = append(keys, destinationRefKey(ref.Kind, namespace, ref.Name))
+	}
+	return keys
+}
+
+// resolveDestinations resolves the references of the alias to the addresses of the referenced resources. References
+// to resources in other namespaces are only resolved, if allowCrossNamespace is set. They are read with the uncached
+// reader, as the cache of the manager only holds the namespaces it watches.
+func resolveDestinations(ctx context.Context, c, uncached client.Reader, alias *operatorv1alpha1.Alias, allowCrossNamespace bool) (aliasDestinations, error) {
+	dest := aliasDestinations{all: slices.Clone(alias.Spec.Destination)}
+
+	for _, ref := range alias.Spec.DestinationRefs {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = alias.Namespace
+		}
+		kind := ref.Kind
+		if kind == "" {
+			kind = "User"
+		}
+		name := fmt.Sprintf("%s %s/%s", kind, namespace, ref.Name)
+		reader := c
+		if namespace != alias.Namespace {
+			if !allowCrossNamespace {
+				dest.unresolved = append(dest.unresolved, name+" is in another namespace")
+				continue
+			}
+			reader = uncached
+		}
+
+		var obj client.Object
+		if kind == "Alias" {
+			obj = &operatorv1alpha1.Alias{}
+		} else {
+			obj = &operatorv1alpha1.User{}
+		}
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				dest.unresolved = append(dest.unresolved, name+" not found")
+				continue
+			}
+			return dest, err
+		}
+		if obj.GetDeletionTimestamp() != nil {
+			dest.unresolved = append(dest.unresolved, name+" is being deleted")
+			continue
+		}
+
+		_, address := indexValue(obj)
+		if !slices.Contains(dest.refs, address) {
+			dest.refs = append(dest.refs, address)
+		}
+		if !slices.Contains(dest.all, address) {
+			dest.all = append(dest.all, address)
+		}
+	}
+	return dest, nil
+}
+
+// setDestinationsResolvedCondition reports the unresolved references of the alias, if it has any references.
+func setDestinationsResolvedCondition(alias *operatorv1alpha1.Alias, dest aliasDestinations) {
+	if len(alias.Spec.DestinationRefs) == 0 {
+		meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypeDestinationsResolved)
+		return
+	}
+	if len(dest.unresolved) > 0 {
+		meta.SetStatusCondition(&alias.Status.Conditions, getDestinationsResolvedCondition(metav1.ConditionFalse, "Unresolved",
+			"Unresolved destination references: "+summarize(dest.unresolved, 5)))
+		return
+	}
+	meta.SetStatusCondition(&alias.Status.Conditions, getDestinationsResolvedCondition(metav1.ConditionTrue, "Resolved",
+		"All destination references are resolved"))
+}
+
+// validateDestinations returns the state of each destination in MailU: destinations in a domain of MailU must be a
+// user or an alias, destinations in other domains are external.
+func validateDestinations(ctx context.Context, api *mailu.Client, destinations []string) []operatorv1alpha1.DestinationStatus {
+	local := map[string]bool{}
+	states := []operatorv1alpha1.DestinationStatus{}
+	for _, address := range destinations {
+		states = append(states, validateDestination(ctx, api, address, local))
+	}
+	return states
+}
+
+func validateDestination(ctx context.Context, api *mailu.Client, address string, local map[string]bool) operatorv1alpha1.DestinationStatus {
+	state := operatorv1alpha1.DestinationStatus{Address: address}
+
+	_, domain, found := strings.Cut(address, "@")
+	if !found || domain == "" {
+		state.State = operatorv1alpha1.DestinationStateMissing
+		state.Message = "not an e-mail address"
+		return state
+	}
+
+	isLocal, ok := local[domain]
+	if !ok {
+		exists, _, err := domainExists(ctx, api, domain)
+		if err != nil {
+			state.State = operatorv1alpha1.DestinationStateUnknown
+			state.Message = "failed to get domain: " + err.Error()
+			return state
+		}
+		local[domain] = exists
+		isLocal = exists
+	}
+	if !isLocal {
+		state.State = operatorv1alpha1.DestinationStateExternal
+		state.Message = "domain " + domain + " is not known to MailU"
+		return state
+	}
+
+	for _, find := range []func(context.Context, string, ...mailu.RequestEditorFn) (*http.Response, error){api.FindUser, api.FindAlias} {
+		exists, err := addressExists(ctx, find, address)
+		if err != nil {
+			state.State = operatorv1alpha1.DestinationStateUnknown
+			state.Message = "failed to get destination: " + err.Error()
+			return state
+		}
+		if exists {
+			state.State = operatorv1alpha1.DestinationStateExists
+			return state
+		}
+	}
+	state.State = operatorv1alpha1.DestinationStateMissing
+	state.Message = "no user or alias in MailU"
+	return state
+}
+
+// addressExists returns true, if the user or alias is found in MailU.
+func addressExists(ctx context.Context, find func(context.Context, string, ...mailu.RequestEditorFn) (*http.Response, error), address string) (bool, error) {
+	found, err := find(ctx, address)
+	if err != nil {
+		return false, err
+	}
+	defer found.Body.Close() //nolint:errcheck
+
+	_, err = io.ReadAll(found.Body)
+	if err != nil {
+		return false, err
+	}
+
+	switch found.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusBadRequest:
+		return false, errors.New("bad request")
+	case http.StatusBadGateway:
+		fallthrough
+	case http.StatusGatewayTimeout:
+		return false, errors.New("gateway timeout")
+	case http.StatusServiceUnavailable:
+		return false, errors.New("service unavailable")
+	}
+	return false, errors.New("unknown status: " + strconv.Itoa(found.StatusCode))
+}
+
+// setDegradedCondition reports the destinations of the alias which do not exist in MailU.
+func setDegradedCondition(alias *operatorv1alpha1.Alias) {
+	invalid := []string{}
+	for _, d := range alias.Status.Destinations {
+		if d.State != operatorv1alpha1.DestinationStateExists {
+			invalid = append(invalid, fmt.Sprintf("%s (%s)", d.Address, d.State))
+		}
+	}
+	if len(invalid) == 0 {
+		meta.RemoveStatusCondition(&alias.Status.Conditions, ConditionTypeDegraded)
+		return
+	}
+	meta.SetStatusCondition(&alias.Status.Conditions, metav1.Condition{
+		Type:    ConditionTypeDegraded,
+		Status:  metav1.ConditionTrue,
+		Reason:  "InvalidDestinations",
+		Message: "Destinations do not exist in MailU: " + summarize(invalid, 5),
+	})
+}
+
+// enqueueReferencingAliases returns a handler enqueueing all Aliases referencing the changed User or Alias as a
+// destination, so a changed address is propagated.
+func enqueueReferencingAliases(c client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(mapReferencingAliases(c))
+}
+
+func mapReferencingAliases(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var kind string
+		switch obj.(type) {
+		case *operatorv1alpha1.User:
+			kind = "User"
+		case *operatorv1alpha1.Alias:
+			kind = "Alias"
+		default:
+			return nil
+		}
+
+		aliases := &operatorv1alpha1.AliasList{}
+		key := destinationRefKey(kind, obj.GetNamespace(), obj.GetName())
+		if err := c.List(ctx, aliases, client.MatchingFields{IndexDestinationRefs: key}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list aliases referencing "+key)
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, a := range aliases.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: a.Namespace, Name: a.Name},
+			})
+		}
+		return requests
+	}
+}
+
+func getDestinationsResolvedCondition(status metav1.ConditionStatus, reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionTypeDestinationsResolved,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+func Test_resolveDestinations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "john.doe", Domain: "example.com"},
+	}
+	other := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "jane", Namespace: "other"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "jane.doe", Domain: "example.com"},
+	}
+	team := &operatorv1alpha1.Alias{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"},
+		Spec:       operatorv1alpha1.AliasSpec{Name: "team", Domain: "example.com"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(user, other, team).Build()
+
+	alias := &operatorv1alpha1.Alias{
+		ObjectMeta: metav1.ObjectMeta{Name: "info", Namespace: "default"},
+		Spec: operatorv1alpha1.AliasSpec{Name: "info", Domain: "example.com", Destination: []string{"john.doe@example.com"},
+			DestinationRefs: []operatorv1alpha1.DestinationRef{
+				{Kind: "User", Name: "john"},
+				{Kind: "Alias", Name: "team"},
+				{Kind: "User", Name: "jane", Namespace: "other"},
+				{Kind: "User", Name: "missing"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		allowCrossNamespace bool
+		wantAll             []string
+		wantRefs            []string
+		wantUnresolved      int
+	}{
+		{
+			name:           "same namespace",
+			wantAll:        []string{"john.doe@example.com", "team@example.com"},
+			wantRefs:       []string{"john.doe@example.com", "team@example.com"},
+			wantUnresolved: 2,
+		},
+		{
+			name:                "cross namespace",
+			allowCrossNamespace: true,
+			wantAll:             []string{"john.doe@example.com", "team@example.com", "jane.doe@example.com"},
+			wantRefs:            []string{"john.doe@example.com", "team@example.com", "jane.doe@example.com"},
+			wantUnresolved:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveDestinations(context.Background(), c, c, alias, tt.allowCrossNamespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.all, tt.wantAll) {
+				t.Errorf("resolveDestinations() all = %v, want %v", got.all, tt.wantAll)
+			}
+			if !reflect.DeepEqual(got.refs, tt.wantRefs) {
+				t.Errorf("resolveDestinations() refs = %v, want %v", got.refs, tt.wantRefs)
+			}
+			if len(got.unresolved) != tt.wantUnresolved {
+				t.Errorf("resolveDestinations() unresolved = %v, want %d", got.unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func Test_resolveDestinations_uncached(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "mail"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "john.doe", Domain: "example.com"},
+	}
+	other := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "jane", Namespace: "other"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "jane.doe", Domain: "example.com"},
+	}
+	// the cache of the manager only holds the namespace "mail"
+	cached := fake.NewClientBuilder().WithScheme(scheme).WithObjects(user).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if key.Namespace != "mail" {
+				return fmt.Errorf("unable to get: %s because of unknown namespace for the cache", key)
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+	uncached := fake.NewClientBuilder().WithScheme(scheme).WithObjects(user, other).Build()
+
+	alias := &operatorv1alpha1.Alias{
+		ObjectMeta: metav1.ObjectMeta{Name: "info", Namespace: "mail"},
+		Spec: operatorv1alpha1.AliasSpec{Name: "info", Domain: "example.com",
+			DestinationRefs: []operatorv1alpha1.DestinationRef{
+				{Kind: "User", Name: "john"},
+				{Kind: "User", Name: "jane", Namespace: "other"},
+			},
+		},
+	}
+
+	got, err := resolveDestinations(context.Background(), cached, uncached, alias, true)
+	if err != nil {
+		t.Fatalf("resolveDestinations() error = %v", err)
+	}
+	want := []string{"john.doe@example.com", "jane.doe@example.com"}
+	if !reflect.DeepEqual(got.refs, want) || len(got.unresolved) != 0 {
+		t.Errorf("resolveDestinations() = %v, unresolved %v, want %v", got.refs, got.unresolved, want)
+	}
+
+	if _, err := resolveDestinations(context.Background(), cached, cached, alias, true); err == nil {
+		t.Errorf("resolveDestinations() with the cached reader expected an error")
+	}
+}
+
+func Test_mapReferencingAliases(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	referencing := &operatorv1alpha1.Alias{
+		ObjectMeta: metav1.ObjectMeta{Name: "info", Namespace: "default"},
+		Spec: operatorv1alpha1.AliasSpec{Name: "info", Domain: "example.com",
+			DestinationRefs: []operatorv1alpha1.DestinationRef{{Kind: "User", Name: "john"}}},
+	}
+	unrelated := &operatorv1alpha1.Alias{
+		ObjectMeta: metav1.ObjectMeta{Name: "sales", Namespace: "default"},
+		Spec: operatorv1alpha1.AliasSpec{Name: "sales", Domain: "example.com",
+			DestinationRefs: []operatorv1alpha1.DestinationRef{{Kind: "Alias", Name: "john"}}},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(referencing, unrelated).
+		WithIndex(&operatorv1alpha1.Alias{}, IndexDestinationRefs, func(obj client.Object) []string {
+			return destinationRefKeys(obj.(*operatorv1alpha1.Alias))
+		}).Build()
+	mapFunc := mapReferencingAliases(c)
+
+	user := &operatorv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "default"}}
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "info"}}}
+	if got := mapFunc(context.Background(), user); !reflect.DeepEqual(got, want) {
+		t.Errorf("mapReferencingAliases() = %v, want %v", got, want)
+	}
+
+	user.Namespace = "other"
+	if got := mapFunc(context.Background(), user); len(got) != 0 {
+		t.Errorf("mapReferencingAliases() = %v for a user in another namespace", got)
+	}
+}
+
+func Test_validateDestinations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/domain/example.com", "/user/john.doe@example.com", "/alias/team@example.com":
+			w.WriteHeader(http.StatusOK)
+		case "/user/broken@example.com":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	api, err := mailu.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := validateDestinations(context.Background(), api, []string{
+		"john.doe@example.com", "team@example.com", "missing@example.com", "someone@example.org", "broken@example.com",
+	})
+	want := []operatorv1alpha1.DestinationState{
+		operatorv1alpha1.DestinationStateExists,
+		operatorv1alpha1.DestinationStateExists,
+		operatorv1alpha1.DestinationStateMissing,
+		operatorv1alpha1.DestinationStateExternal,
+		operatorv1alpha1.DestinationStateUnknown,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("validateDestinations() = %v", got)
+	}
+	for i := range want {
+		if got[i].State != want[i] {
+			t.Errorf("validateDestinations() state of %s = %s, want %s", got[i].Address, got[i].State, want[i])
+		}
+	}
+
+	alias := &operatorv1alpha1.Alias{Status: operatorv1alpha1.AliasStatus{Destinations: got}}
+	setDegradedCondition(alias)
+	if !meta.IsStatusConditionTrue(alias.Status.Conditions, ConditionTypeDegraded) {
+		t.Errorf("setDegradedCondition() did not set the Degraded condition")
+	}
+	alias.Status.Destinations = got[:2]
+	setDegradedCondition(alias)
+	if meta.FindStatusCondition(alias.Status.Conditions, ConditionTypeDegraded) != nil {
+		t.Errorf("setDegradedCondition() did not remove the Degraded condition")
+	}
+}
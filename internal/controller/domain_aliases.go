@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+// domainAlias is a role or catch-all alias of a domain in MailU.
+type domainAlias struct {
+	address     string
+	destination []string
+	wildcard    bool
+}
+
+// domainAliases returns the role aliases of the domain sorted by role, followed by the catch-all alias.
+func domainAliases(domain *operatorv1alpha1.Domain) []domainAlias {
+	aliases := []domainAlias{}
+	roles := make([]string, 0, len(domain.Spec.RoleAliases))
+	for role := range domain.Spec.RoleAliases {
+		roles = append(roles, role)
+	}
+	slices.Sort(roles)
+	for _, role := range roles {
+		aliases = append(aliases, domainAlias{
+			address:     role + "@" + domain.Spec.Name,
+			destination: slices.Clone(domain.Spec.RoleAliases[role]),
+		})
+	}
+	if len(domain.Spec.CatchAll) > 0 {
+		aliases = append(aliases, domainAlias{
+			address:     "%@" + domain.Spec.Name,
+			destination: slices.Clone(domain.Spec.CatchAll),
+			wildcard:    true,
+		})
+	}
+	return aliases
+}
+
+// reconcileAliases creates or updates the role and catch-all aliases of the domain in MailU, and deletes the aliases
+// removed from the spec. Existing aliases not created by the domain are left untouched.
+func (r *DomainReconciler) reconcileAliases(ctx context.Context, domain *operatorv1alpha1.Domain, result ctrl.Result) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	desired := domainAliases(domain)
+	if len(desired) == 0 && len(domain.Status.Aliases) == 0 {
+		return result, nil
+	}
+	if observeOnly(domain, r.ObserveOnly) {
+		return r.planAliases(ctx, domain, desired, result), nil
+	}
+
+	retryAny := false
+	addresses := []string{}
+	states := []operatorv1alpha1.DomainAliasStatus{}
+	for _, alias := range desired {
+		state, message, retry := applyOwnedAlias(ctx, r.ApiClient, domain, alias.address, alias.destination, alias.wildcard)
+		if state == operatorv1alpha1.UserAliasStateApplied {
+			logr.Info("applied alias of domain", "alias", alias.address)
+		}
+		retryAny = retryAny || retry
+		addresses = append(addresses, alias.address)
+		states = append(states, operatorv1alpha1.DomainAliasStatus{Address: alias.address, State: state, Message: message})
+	}
+
+	// aliases applied before, but removed from the spec, are deleted
+	for _, state := range domain.Status.Aliases {
+		if state.State == operatorv1alpha1.UserAliasStateConflict || slices.Contains(addresses, state.Address) {
+			continue
+		}
+		retry, err := deleteAlias(ctx, r.ApiClient, state.Address)
+		if err != nil || retry {
+			// keep the alias in the status to delete it again with the next reconciliation
+			state.Message = deleteAliasMessage(err)
+			states = append(states, state)
+			retryAny = true
+			continue
+		}
+		logr.Info("deleted alias of domain", "alias", state.Address)
+	}
+
+	domain.Status.Aliases = states
+	if retryAny {
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+	return result, nil
+}
+
+// planAliases reports the role and catch-all aliases that would be created, updated or deleted in MailU.
+func (r *DomainReconciler) planAliases(ctx context.Context, domain *operatorv1alpha1.Domain, desired []domainAlias, result ctrl.Result) ctrl.Result {
+	create, update, remove := []string{}, []string{}, []string{}
+	addresses := []string{}
+	for _, alias := range desired {
+		addresses = append(addresses, alias.address)
+		action, retry, err := planOwnedAlias(ctx, r.ApiClient, domain, alias.address, alias.destination, alias.wildcard)
+		if err != nil || retry {
+			log.FromContext(ctx).Info("failed to get alias of domain, requeueing", "alias", alias.address)
+			return ctrl.Result{RequeueAfter: 5 * time.Second}
+		}
+		switch action {
+		case "Create":
+			create = append(create, alias.address)
+		case "Update":
+			update = append(update, alias.address)
+		}
+	}
+	for _, state := range domain.Status.Aliases {
+		if state.State != operatorv1alpha1.UserAliasStateConflict && !slices.Contains(addresses, state.Address) {
+			remove = append(remove, state.Address)
+		}
+	}
+
+	if plan := aliasPlan(create, update, remove); plan != "" {
+		reportPlan(r.Recorder, domain, &domain.Status.Conditions, "Update", "Would apply aliases of domain "+domain.Spec.Name+" in MailU: "+plan)
+	}
+	return result
+}
@@ -9,11 +9,14 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -31,15 +34,21 @@ const (
 // DomainReconciler reconciles a Domain object
 type DomainReconciler struct {
 	client.Client
-	Scheme    *runtime.Scheme
-	ApiURL    string
-	ApiToken  string
-	ApiClient *mailu.Client
+	Scheme         *runtime.Scheme
+	Recorder       events.EventRecorder
+	ApiURL         string
+	ApiToken       string
+	ApiClient      *mailu.Client
+	ResyncInterval time.Duration
+	DriftPolicy    string
+	ObserveOnly    bool
+	DeletionPolicy operatorv1alpha1.DeletionPolicy
 }
 
 //+kubebuilder:rbac:groups=operator.mailu.io,resources=domains,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=operator.mailu.io,resources=domains/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=operator.mailu.io,resources=domains/finalizers,verbs=update
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=users;aliases;mailinglists,verbs=get;list;watch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -65,6 +74,31 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, domain *operatorv1alph
 		controllerutil.AddFinalizer(domain, FinalizerName)
 	}
 
+	// skip all calls to MailU, including the deletion, until the annotation is removed
+	if paused(domain) {
+		meta.SetStatusCondition(&domain.Status.Conditions, getPausedCondition())
+		logr.Info("reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&domain.Status.Conditions, ConditionTypePaused)
+
+	// only the oldest resource targeting an object in MailU manages it, the others must not call MailU
+	winner, err := findConflictWinner(ctx, r.Client, "Domain", domain)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if winner != "" {
+		msg := fmt.Sprintf("%s is already managed by %s", domain.Spec.Name, winner)
+		meta.SetStatusCondition(&domain.Status.Conditions, getConflictCondition(msg))
+		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Conflict", msg))
+		logr.Info("conflicting resource, skipping reconciliation", "winner", winner)
+		if domainOriginal.DeletionTimestamp != nil {
+			controllerutil.RemoveFinalizer(domain, FinalizerName)
+		}
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&domain.Status.Conditions, ConditionTypeConflict)
+
 	result, err := r.reconcile(ctx, domain)
 	if err != nil {
 		return result, err
@@ -91,6 +125,14 @@ func (r *DomainReconciler) reconcile(ctx context.Context, domain *operatorv1alph
 		r.ApiClient = api
 	}
 
+	if !observeOnly(domain, r.ObserveOnly) {
+		meta.RemoveStatusCondition(&domain.Status.Conditions, ConditionTypeObserveOnly)
+	}
+
+	if domain.DeletionTimestamp != nil && deletionPolicy(domain.Spec.DeletionPolicy, r.DeletionPolicy) == operatorv1alpha1.DeletionPolicyRetain {
+		return r.retain(ctx, domain)
+	}
+
 	foundDomain, retry, err := r.getDomain(ctx, domain)
 	if err != nil {
 		if retry {
@@ -104,23 +146,45 @@ func (r *DomainReconciler) reconcile(ctx context.Context, domain *operatorv1alph
 	}
 
 	if domain.DeletionTimestamp != nil {
-		if foundDomain == nil {
-			// no need to delete it, if it does not exist
+		if foundDomain == nil || (domain.Status.ObservedGeneration == 0 && !ownedBy(foundDomain.Comment, domain)) {
+			// no need to delete it, if it does not exist or was never applied by this resource
 			return ctrl.Result{}, nil
 		}
 		return r.delete(ctx, domain)
 	}
 
+	var result ctrl.Result
 	if foundDomain == nil {
-		return r.create(ctx, domain)
+		// the domain was applied before, so it has been deleted in MailU
+		if domain.Status.ObservedGeneration > 0 {
+			meta.SetStatusCondition(&domain.Status.Conditions, getDriftedCondition("Domain was deleted in MailU"))
+			if driftPolicy(domain, r.DriftPolicy) == DriftPolicyReport {
+				recordEvent(r.Recorder, domain, corev1.EventTypeWarning, "Drifted", "Report", "Domain was deleted in MailU")
+				logr.Info("domain was deleted in MailU, not recreating it")
+				return ctrl.Result{RequeueAfter: resyncInterval(domain, r.ResyncInterval)}, nil
+			}
+			recordEvent(r.Recorder, domain, corev1.EventTypeWarning, "Drifted", "Correct", "Domain was deleted in MailU, recreating it")
+		}
+		result, err = r.create(ctx, domain)
+	} else {
+		result, err = r.update(ctx, domain, foundDomain)
 	}
-
-	return r.update(ctx, domain, foundDomain)
+	// the aliases of the domain can only be applied once the domain exists in MailU
+	if err != nil || !meta.IsStatusConditionTrue(domain.Status.Conditions, DomainConditionTypeReady) {
+		return result, err
+	}
+	return r.reconcileAliases(ctx, domain, result)
 }
 
 func (r *DomainReconciler) create(ctx context.Context, domain *operatorv1alpha1.Domain) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
+	if observeOnly(domain, r.ObserveOnly) {
+		reportPlan(r.Recorder, domain, &domain.Status.Conditions, "Create", "Would create domain "+domain.Spec.Name+" in MailU")
+		logr.Info("observe-only, not creating domain")
+		return ctrl.Result{RequeueAfter: resyncInterval(domain, r.ResyncInterval)}, nil
+	}
+
 	retry, err := r.createDomain(ctx, domain)
 	if err != nil {
 		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
@@ -136,32 +200,83 @@ func (r *DomainReconciler) create(ctx context.Context, domain *operatorv1alpha1.
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
+	meta.RemoveStatusCondition(&domain.Status.Conditions, ConditionTypeDrifted)
 	meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionTrue, "Created", "Domain created in MailU"))
+	domain.Status.ObservedGeneration = domain.Generation
 	logr.Info("created domain")
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: resyncInterval(domain, r.ResyncInterval)}, nil
 }
 
 func (r *DomainReconciler) update(ctx context.Context, domain *operatorv1alpha1.Domain, apiDomain *mailu.Domain) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
+	comment := withOwnerMarker(domain.Spec.Comment, domain)
 	newDomain := mailu.Domain{
 		Name:          domain.Spec.Name,
 		Alternatives:  &domain.Spec.Alternatives,
-		Comment:       &domain.Spec.Comment,
+		Comment:       &comment,
 		MaxAliases:    &domain.Spec.MaxAliases,
 		MaxQuotaBytes: &domain.Spec.MaxQuotaBytes,
 		MaxUsers:      &domain.Spec.MaxUsers,
 		SignupEnabled: &domain.Spec.SignupEnabled,
 	}
 
+	// keep the values of ignored fields as they are in MailU
+	newDomain, err := ignoreFields(newDomain, *apiDomain, domainFields, domain.Spec.IgnoreFields)
+	if err != nil {
+		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		logr.Error(err, "failed to apply ignored fields")
+		return ctrl.Result{}, err
+	}
+
+	// the domain exists in MailU, but was never applied by this resource
+	if domain.Status.ObservedGeneration == 0 && !ownedBy(apiDomain.Comment, domain) {
+		diff := withoutOwnerMarkerDiff(diffFields(newDomain, *apiDomain, domainFields), newDomain.Comment, apiDomain.Comment)
+		reason, message := checkAdoption(domain.Spec.AdoptionPolicy, "Domain", diff)
+		if reason != "" {
+			meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, reason, message))
+			logr.Info("not adopting existing domain: " + message)
+			return ctrl.Result{}, nil
+		}
+		meta.SetStatusCondition(&domain.Status.Conditions, getAdoptedCondition("Domain adopted from MailU"))
+		recordEvent(r.Recorder, domain, corev1.EventTypeNormal, "Adopted", "Adopt", "Adopted existing domain %s from MailU", domain.Spec.Name)
+		logr.Info("adopting existing domain")
+	}
+
 	jsonNew, _ := json.Marshal(newDomain) //nolint:errcheck
 	jsonOld, _ := json.Marshal(apiDomain) //nolint:errcheck
 
 	if reflect.DeepEqual(jsonNew, jsonOld) {
+		meta.RemoveStatusCondition(&domain.Status.Conditions, ConditionTypeDrifted)
 		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionTrue, "Updated", "Domain updated in MailU"))
+		domain.Status.ObservedGeneration = domain.Generation
+		if observeOnly(domain, r.ObserveOnly) {
+			reportPlan(r.Recorder, domain, &domain.Status.Conditions, "None", "Domain is up to date in MailU")
+		}
 		logr.Info("domain is up to date, no change needed")
-		return ctrl.Result{}, nil
+		return ctrl.Result{RequeueAfter: resyncInterval(domain, r.ResyncInterval)}, nil
+	}
+
+	// the spec did not change since it was last applied, so the domain has been changed in MailU
+	// (a missing ownership marker is no drift, it is added with the next update)
+	drift := withoutOwnerMarkerDiff(diffFields(newDomain, *apiDomain, domainFields), newDomain.Comment, apiDomain.Comment)
+	if domain.Status.ObservedGeneration == domain.Generation && len(drift) > 0 {
+		fields := strings.Join(drift, ", ")
+		meta.SetStatusCondition(&domain.Status.Conditions, getDriftedCondition("Domain differs in MailU: "+fields))
+		if driftPolicy(domain, r.DriftPolicy) == DriftPolicyReport {
+			recordEvent(r.Recorder, domain, corev1.EventTypeWarning, "Drifted", "Report", "Domain differs in MailU: %s", fields)
+			logr.Info("domain differs in MailU, not correcting it", "fields", fields)
+			return ctrl.Result{RequeueAfter: resyncInterval(domain, r.ResyncInterval)}, nil
+		}
+		recordEvent(r.Recorder, domain, corev1.EventTypeWarning, "Drifted", "Correct", "Domain differs in MailU, correcting: %s", fields)
+	}
+
+	if observeOnly(domain, r.ObserveOnly) {
+		fields := strings.Join(diffFields(newDomain, *apiDomain, domainFields), ", ")
+		reportPlan(r.Recorder, domain, &domain.Status.Conditions, "Update", "Would update domain "+domain.Spec.Name+" in MailU: "+fields)
+		logr.Info("observe-only, not updating domain", "fields", fields)
+		return ctrl.Result{RequeueAfter: resyncInterval(domain, r.ResyncInterval)}, nil
 	}
 
 	retry, err := r.updateDomain(ctx, newDomain)
@@ -179,15 +294,28 @@ func (r *DomainReconciler) update(ctx context.Context, domain *operatorv1alpha1.
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
+	meta.RemoveStatusCondition(&domain.Status.Conditions, ConditionTypeDrifted)
 	meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionTrue, "Updated", "Domain updated in MailU"))
+	domain.Status.ObservedGeneration = domain.Generation
 	logr.Info("updated domain")
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: resyncInterval(domain, r.ResyncInterval)}, nil
 }
 
 func (r *DomainReconciler) delete(ctx context.Context, domain *operatorv1alpha1.Domain) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
+	if observeOnly(domain, r.ObserveOnly) {
+		reportPlan(r.Recorder, domain, &domain.Status.Conditions, "Delete", "Would delete domain "+domain.Spec.Name+" in MailU")
+		logr.Info("observe-only, not deleting domain")
+		return ctrl.Result{}, nil
+	}
+
+	// deleting the domain in MailU deletes all its users and aliases as well
+	if result, err := r.deleteDependents(ctx, domain); err != nil || result.RequeueAfter > 0 {
+		return result, err
+	}
+
 	retry, err := r.deleteDomain(ctx, domain)
 	if err != nil {
 		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
@@ -207,6 +335,125 @@ func (r *DomainReconciler) delete(ctx context.Context, domain *operatorv1alpha1.
 	return ctrl.Result{}, nil
 }
 
+// deleteDependents handles the users and aliases of the domain according to the deletion mode. It requeues the
+// request, until the domain can be deleted.
+func (r *DomainReconciler) deleteDependents(ctx context.Context, domain *operatorv1alpha1.Domain) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	resources, err := listDependents(ctx, r.Client, domain.Spec.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// only the resources in the namespace of the domain are deleted, those of other namespaces block the deletion
+	if cascading := cascadingDependents(domain, resources); len(cascading) > 0 {
+		for _, obj := range cascading {
+			if obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		msg := fmt.Sprintf("Waiting for %d users and aliases to be deleted", len(cascading))
+		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "DeletingDependents", msg))
+		logr.Info("deleting users and aliases of domain", "count", len(cascading))
+		return ctrl.Result{RequeueAfter: dependencyCheckInterval(domain, r.ResyncInterval)}, nil
+	}
+
+	users, retry, err := r.listDomainUsers(ctx, domain)
+	if err != nil {
+		if retry {
+			logr.Info(fmt.Errorf("failed to list users of domain, requeueing: %w", err).Error())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		logr.Error(err, "failed to list users of domain")
+		return ctrl.Result{}, err
+	}
+
+	blocking := []string{}
+	managed := map[string]bool{}
+	for _, obj := range resources {
+		kind := "Alias"
+		switch obj.(type) {
+		case *operatorv1alpha1.User:
+			kind = "User"
+		case *operatorv1alpha1.MailingList:
+			kind = "MailingList"
+		}
+		blocking = append(blocking, fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName()))
+		_, email := indexValue(obj)
+		managed[email] = true
+	}
+	for _, user := range users {
+		if !managed[user.Email] {
+			blocking = append(blocking, user.Email)
+		}
+	}
+	if len(blocking) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	msg := "Domain still has users or aliases: " + summarize(blocking, 5)
+	if condition := meta.FindStatusCondition(domain.Status.Conditions, DomainConditionTypeReady); condition == nil || condition.Message != msg {
+		recordEvent(r.Recorder, domain, corev1.EventTypeWarning, "DeletionBlocked", "Delete", "%s", msg)
+	}
+	meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "DeletionBlocked", msg))
+	logr.Info("not deleting domain with users or aliases", "count", len(blocking))
+	return ctrl.Result{RequeueAfter: dependencyCheckInterval(domain, r.ResyncInterval)}, nil
+}
+
+// cascadingDependents returns the resources deleted with the domain: with deletionMode Cascade, those in the namespace
+// of the domain.
+func cascadingDependents(domain *operatorv1alpha1.Domain, resources []client.Object) []client.Object {
+	cascading := []client.Object{}
+	if domain.Spec.DeletionMode != operatorv1alpha1.DeletionModeCascade {
+		return cascading
+	}
+	for _, obj := range resources {
+		if obj.GetNamespace() == domain.Namespace {
+			cascading = append(cascading, obj)
+		}
+	}
+	return cascading
+}
+
+func (r *DomainReconciler) listDomainUsers(ctx context.Context, domain *operatorv1alpha1.Domain) ([]mailu.User, bool, error) {
+	found, err := r.ApiClient.ListUserDomain(ctx, domain.Spec.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer found.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(found.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	switch found.StatusCode {
+	case http.StatusOK:
+		users := []mailu.User{}
+		err = json.Unmarshal(body, &users)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return users, false, nil
+	case http.StatusNotFound:
+		return nil, false, nil
+	case http.StatusBadRequest:
+		return nil, false, errors.New("bad request")
+	case http.StatusBadGateway:
+		fallthrough
+	case http.StatusGatewayTimeout:
+		return nil, true, errors.New("gateway timeout")
+	case http.StatusServiceUnavailable:
+		return nil, true, errors.New("service unavailable")
+	}
+	return nil, false, errors.New("unknown status: " + strconv.Itoa(found.StatusCode))
+}
+
 func (r *DomainReconciler) getDomain(ctx context.Context, domain *operatorv1alpha1.Domain) (*mailu.Domain, bool, error) {
 	found, err := r.ApiClient.FindDomain(ctx, domain.Spec.Name)
 	if err != nil {
@@ -243,9 +490,10 @@ func (r *DomainReconciler) getDomain(ctx context.Context, domain *operatorv1alph
 }
 
 func (r *DomainReconciler) createDomain(ctx context.Context, domain *operatorv1alpha1.Domain) (bool, error) {
+	comment := withOwnerMarker(domain.Spec.Comment, domain)
 	res, err := r.ApiClient.CreateDomain(ctx, mailu.Domain{
 		Name:          domain.Spec.Name,
-		Comment:       &domain.Spec.Comment,
+		Comment:       &comment,
 		MaxUsers:      &domain.Spec.MaxUsers,
 		MaxAliases:    &domain.Spec.MaxAliases,
 		MaxQuotaBytes: &domain.Spec.MaxQuotaBytes,
@@ -354,5 +602,9 @@ func getDomainReadyCondition(status metav1.ConditionStatus, reason, message stri
 func (r *DomainReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&operatorv1alpha1.Domain{}).
+		Watches(&operatorv1alpha1.Domain{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.DomainList{})).
+		Watches(&operatorv1alpha1.User{}, enqueueDeletingDomain(mgr.GetClient())).
+		Watches(&operatorv1alpha1.Alias{}, enqueueDeletingDomain(mgr.GetClient())).
+		Watches(&operatorv1alpha1.MailingList{}, enqueueDeletingDomain(mgr.GetClient())).
 		Complete(reconcile.AsReconciler(r.Client, r))
 }
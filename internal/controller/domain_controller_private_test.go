@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func Test_domainAliases(t *testing.T) {
+	domain := &operatorv1alpha1.Domain{
+		Spec: operatorv1alpha1.DomainSpec{
+			Name: "example.com",
+			RoleAliases: map[string][]string{
+				"postmaster": {"admin@example.com"},
+				"abuse":      {"admin@example.com", "security@example.org"},
+			},
+			CatchAll: []string{"admin@example.com"},
+		},
+	}
+	want := []domainAlias{
+		{address: "abuse@example.com", destination: []string{"admin@example.com", "security@example.org"}},
+		{address: "postmaster@example.com", destination: []string{"admin@example.com"}},
+		{address: "%@example.com", destination: []string{"admin@example.com"}, wildcard: true},
+	}
+	if got := domainAliases(domain); !reflect.DeepEqual(got, want) {
+		t.Errorf("domainAliases() = %v, want %v", got, want)
+	}
+}
+
+func Test_cascadingDependents(t *testing.T) {
+	domain := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec:       operatorv1alpha1.DomainSpec{Name: "example.com", DeletionMode: operatorv1alpha1.DeletionModeCascade},
+	}
+	own := &operatorv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "default"}}
+	foreign := &operatorv1alpha1.Alias{ObjectMeta: metav1.ObjectMeta{Name: "info", Namespace: "other"}}
+	resources := []client.Object{own, foreign}
+
+	if got := cascadingDependents(domain, resources); !reflect.DeepEqual(got, []client.Object{own}) {
+		t.Errorf("cascadingDependents() = %v, want only the user in the namespace of the domain", got)
+	}
+
+	domain.Spec.DeletionMode = operatorv1alpha1.DeletionModeBlock
+	if got := cascadingDependents(domain, resources); len(got) != 0 {
+		t.Errorf("cascadingDependents() = %v with deletionMode Block", got)
+	}
+}
+
+func Test_aliasPlan(t *testing.T) {
+	got := aliasPlan([]string{"abuse@example.com"}, nil, []string{"%@example.com", "info@example.com"})
+	want := "create abuse@example.com; delete %@example.com, info@example.com"
+	if got != want {
+		t.Errorf("aliasPlan() = %q, want %q", got, want)
+	}
+	if got := aliasPlan(nil, nil, nil); got != "" {
+		t.Errorf("aliasPlan() = %q without changes", got)
+	}
+}
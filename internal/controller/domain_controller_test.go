@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/ghttp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -130,8 +131,9 @@ var _ = Describe("Domain Controller", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
-				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(1))
+				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(2))
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, DomainConditionTypeReady)).To(BeFalse())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeDrifted)).To(BeTrue())
 				Expect(result.RequeueAfter).To(BeNumerically(">", 0))
 			})
 		})
@@ -202,6 +204,7 @@ var _ = Describe("Domain Controller", func() {
 
 			It("requeues the request, if a retryable error occurs", func() {
 				prepareFindDomain(res, http.StatusOK)
+				prepareListDomainUsers(res, http.StatusOK)
 				prepareDeleteDomain(res, http.StatusServiceUnavailable)
 
 				result, err := reconcile(false)
@@ -214,6 +217,7 @@ var _ = Describe("Domain Controller", func() {
 			It("deletes the domain", func() {
 				res = resAfterReconciliation.DeepCopy()
 				prepareFindDomain(res, http.StatusOK)
+				prepareListDomainUsers(res, http.StatusOK)
 				prepareDeleteDomain(res, http.StatusOK)
 
 				_, err := reconcile(true)
@@ -238,14 +242,20 @@ var _ = Describe("Domain Controller", func() {
 				Expect(res.GetFinalizers()).To(HaveLen(0))
 				Expect(res.Status.Conditions).To(HaveLen(0))
 
-				prepareFindDomain(res, http.StatusOK)
+				// the existing domain was not created by the operator, so it carries no ownership marker
+				existing := res.DeepCopy()
+				existing.UID = ""
+				prepareFindDomain(existing, http.StatusOK)
+				// the ownership marker is added to the comment
+				preparePatchDomain(res, http.StatusOK)
 
 				_, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
-				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(1))
+				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(2))
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, DomainConditionTypeReady)).To(BeTrue())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeAdopted)).To(BeTrue())
 			})
 		})
 
@@ -268,5 +278,184 @@ var _ = Describe("Domain Controller", func() {
 				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.Domain{}))
 			})
 		})
+
+		When("pausing a Domain", func() {
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.Domain{}, "paused", "paused.example.com").(*operatorv1alpha1.Domain)
+				res.Annotations = map[string]string{AnnotationPaused: "true"}
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not call MailU", func() {
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypePaused)).To(BeTrue())
+			})
+
+			It("does not delete the domain", func() {
+				res = resAfterReconciliation.DeepCopy()
+				err := k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
+			})
+
+			It("resumes, when the annotation is removed", func() {
+				res = resAfterReconciliation.DeepCopy()
+				res.Annotations = nil
+				err := k8sClient.Update(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				// the domain was never applied, so it carries no ownership marker and must not be deleted
+				existing := res.DeepCopy()
+				existing.UID = ""
+				prepareFindDomain(existing, http.StatusOK)
+
+				_, err = reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.Domain{}))
+			})
+		})
+
+		When("deleting a Domain with users", func() {
+			var user *operatorv1alpha1.User
+
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.Domain{}, "blocked", "blocked.example.com").(*operatorv1alpha1.Domain)
+				res.Finalizers = []string{FinalizerName}
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				user = CreateResource(operatorv1alpha1.User{}, "blocked", "blocked.example.com").(*operatorv1alpha1.User)
+				err = k8sClient.Create(ctx, user)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("blocks the deletion by default", func() {
+				prepareFindDomain(res, http.StatusOK)
+				prepareListDomainUsers(res, http.StatusOK, "blocked@blocked.example.com", "other@blocked.example.com")
+
+				result, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, DomainConditionTypeReady)
+				Expect(condition.Reason).To(Equal("DeletionBlocked"))
+				Expect(condition.Message).To(Equal("Domain still has users or aliases: User default/blocked, other@blocked.example.com"))
+			})
+
+			It("deletes the users first with deletionMode Cascade", func() {
+				res = resAfterReconciliation.DeepCopy()
+				res.Spec.DeletionMode = operatorv1alpha1.DeletionModeCascade
+				err := k8sClient.Update(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindDomain(res, http.StatusOK)
+
+				result, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, DomainConditionTypeReady)
+				Expect(condition.Reason).To(Equal("DeletingDependents"))
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: user.GetName(), Namespace: user.GetNamespace()}, user)
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			})
+
+			It("deletes the domain, once the users are gone", func() {
+				res = resAfterReconciliation.DeepCopy()
+				prepareFindDomain(res, http.StatusOK)
+				prepareDeleteDomain(res, http.StatusOK)
+
+				_, err := reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.Domain{}))
+			})
+		})
+
+		When("creating a Domain with role aliases", func() {
+			// aliasOf returns the role alias of the domain in MailU
+			aliasOf := func(domain *operatorv1alpha1.Domain, role string) *operatorv1alpha1.Alias {
+				return &operatorv1alpha1.Alias{
+					ObjectMeta: *domain.ObjectMeta.DeepCopy(),
+					Spec: operatorv1alpha1.AliasSpec{
+						Name: role, Domain: domain.Spec.Name, Destination: domain.Spec.RoleAliases[role],
+					},
+				}
+			}
+
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.Domain{}, "roles", "roles.example.com").(*operatorv1alpha1.Domain)
+				res.Spec.RoleAliases = map[string][]string{"postmaster": {"admin@roles.example.com"}}
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("creates the domain and its role aliases", func() {
+				prepareFindDomain(res, http.StatusNotFound)
+				prepareCreateDomain(res, http.StatusOK)
+				prepareFindAlias(aliasOf(res, "postmaster"), http.StatusNotFound)
+				prepareCreateAlias(aliasOf(res, "postmaster"), http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, DomainConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.Aliases).To(Equal([]operatorv1alpha1.DomainAliasStatus{
+					{Address: "postmaster@roles.example.com", State: operatorv1alpha1.UserAliasStateApplied},
+				}))
+			})
+
+			It("deletes role aliases removed from the spec", func() {
+				res = resAfterReconciliation.DeepCopy()
+				removed := aliasOf(res, "postmaster")
+				res.Spec.RoleAliases = nil
+				err := k8sClient.Update(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindDomain(res, http.StatusOK)
+				prepareDeleteAlias(removed, http.StatusOK)
+
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation.Status.Aliases).To(BeEmpty())
+			})
+		})
 	})
 })
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+const (
+	ConditionTypeForwardingDenied = "ForwardingDenied"
+)
+
+// forwardingDenied returns a message naming the ForwardingPolicy and its rule denying a forward destination of the
+// user, or an empty string if forwarding is disabled or all destinations are allowed.
+func forwardingDenied(ctx context.Context, c client.Reader, user *operatorv1alpha1.User) (string, error) {
+	if !user.Spec.ForwardEnabled || len(user.Spec.ForwardDestination) == 0 {
+		return "", nil
+	}
+
+	policies := &operatorv1alpha1.ForwardingPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return "", err
+	}
+	if len(policies.Items) == 0 {
+		return "", nil
+	}
+	sort.Slice(policies.Items, func(i, j int) bool { return policies.Items[i].Name < policies.Items[j].Name })
+
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: user.Namespace}, namespace); err != nil {
+		return "", err
+	}
+
+	for _, policy := range policies.Items {
+		selected, err := selectsNamespace(policy.Spec.NamespaceSelector, namespace)
+		if err != nil {
+			return "", err
+		}
+		if !selected {
+			continue
+		}
+		for _, destination := range user.Spec.ForwardDestination {
+			if rule := denyingRule(policy.Spec, destination); rule != "" {
+				return fmt.Sprintf("Forward destination %s is denied by ForwardingPolicy %s: %s", destination, policy.Name, rule), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// selectsNamespace returns true if the namespace matches the selector, a missing selector matches all namespaces.
+func selectsNamespace(selector *metav1.LabelSelector, namespace *corev1.Namespace) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// denyingRule returns the rule of the policy denying forwarding to the destination, or an empty string if it is allowed.
+// Denied domains take precedence over allowed domains.
+func denyingRule(spec operatorv1alpha1.ForwardingPolicySpec, destination string) string {
+	domain := strings.ToLower(destination[strings.LastIndex(destination, "@")+1:])
+	for _, pattern := range spec.DeniedDomains {
+		if matchDomain(pattern, domain) {
+			return "denied domain " + pattern
+		}
+	}
+	if len(spec.AllowedDomains) == 0 {
+		return ""
+	}
+	for _, pattern := range spec.AllowedDomains {
+		if matchDomain(pattern, domain) {
+			return ""
+		}
+	}
+	return "domain " + domain + " is not allowed"
+}
+
+// matchDomain returns true if the domain matches the pattern, an invalid pattern matches no domain.
+func matchDomain(pattern, domain string) bool {
+	matched, err := path.Match(strings.ToLower(pattern), domain)
+	return err == nil && matched
+}
+
+// enqueueForwardingUsers returns a handler enqueueing all Users forwarding e-mail, so a changed ForwardingPolicy is
+// enforced.
+func enqueueForwardingUsers(c client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(mapForwardingUsers(c))
+}
+
+func mapForwardingUsers(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		users := &operatorv1alpha1.UserList{}
+		if err := c.List(ctx, users); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list users of forwarding policy "+obj.GetName())
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, u := range users.Items {
+			if !u.Spec.ForwardEnabled {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: u.Namespace, Name: u.Name},
+			})
+		}
+		return requests
+	}
+}
+
+func getForwardingDeniedCondition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionTypeForwardingDenied,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Denied",
+		Message: message,
+	}
+}
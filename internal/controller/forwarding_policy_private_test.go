@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func Test_denyingRule(t *testing.T) {
+	spec := operatorv1alpha1.ForwardingPolicySpec{
+		AllowedDomains: []string{"example.com", "*.example.com"},
+		DeniedDomains:  []string{"mail.example.com"},
+	}
+
+	tests := []struct {
+		name        string
+		spec        operatorv1alpha1.ForwardingPolicySpec
+		destination string
+		want        string
+	}{
+		{name: "allowed domain", spec: spec, destination: "john@example.com"},
+		{name: "allowed subdomain", spec: spec, destination: "john@Team.Example.com"},
+		{name: "denied takes precedence", spec: spec, destination: "john@mail.example.com", want: "denied domain mail.example.com"},
+		{name: "not allowed", spec: spec, destination: "john@example.org", want: "domain example.org is not allowed"},
+		{name: "all allowed without allowed domains", spec: operatorv1alpha1.ForwardingPolicySpec{}, destination: "john@example.org"},
+		{name: "invalid pattern matches nothing", spec: operatorv1alpha1.ForwardingPolicySpec{DeniedDomains: []string{"["}}, destination: "john@example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := denyingRule(tt.spec, tt.destination); got != tt.want {
+				t.Errorf("denyingRule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_forwardingDenied(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	external := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "external", Labels: map[string]string{"tenant": "external"}}}
+	internal := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "internal"}}
+	policy := &operatorv1alpha1.ForwardingPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "external"},
+		Spec: operatorv1alpha1.ForwardingPolicySpec{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "external"}},
+			AllowedDomains:    []string{"example.com"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(external, internal, policy).Build()
+
+	user := func(namespace string, enabled bool) *operatorv1alpha1.User {
+		return &operatorv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: namespace},
+			Spec: operatorv1alpha1.UserSpec{Name: "john", Domain: "example.com", ForwardEnabled: enabled,
+				ForwardDestination: []string{"john@example.com", "john@example.org"}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		user *operatorv1alpha1.User
+		want string
+	}{
+		{name: "selected namespace", user: user("external", true),
+			want: "Forward destination john@example.org is denied by ForwardingPolicy external: domain example.org is not allowed"},
+		{name: "other namespace", user: user("internal", true)},
+		{name: "forwarding disabled", user: user("external", false)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := forwardingDenied(context.Background(), c, tt.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("forwardingDenied() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mapForwardingUsers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	forwarding := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "forwarding", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "forwarding", Domain: "example.com", ForwardEnabled: true},
+	}
+	other := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "other", Domain: "example.com"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(forwarding, other).Build()
+
+	policy := &operatorv1alpha1.ForwardingPolicy{ObjectMeta: metav1.ObjectMeta{Name: "external"}}
+	got := mapForwardingUsers(c)(context.Background(), policy)
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "forwarding", Namespace: "default"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mapForwardingUsers() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,362 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+const (
+	MailingListConditionTypeReady = "MailingListReady"
+)
+
+// MailingListReconciler reconciles a MailingList object
+type MailingListReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       events.EventRecorder
+	ApiURL         string
+	ApiToken       string
+	ApiClient      *mailu.Client
+	ResyncInterval time.Duration
+	ObserveOnly    bool
+	DeletionPolicy operatorv1alpha1.DeletionPolicy
+}
+
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=mailinglists,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=mailinglists/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=mailinglists/finalizers,verbs=update
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=users,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// The mailing list is an alias in MailU with the addresses of the selected Users as destinations.
+func (r *MailingListReconciler) Reconcile(ctx context.Context, list *operatorv1alpha1.MailingList) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	listOriginal := list.DeepCopy()
+
+	// apply patches at the end, before returning
+	defer func() {
+		if err := r.Patch(ctx, list.DeepCopy(), client.MergeFrom(listOriginal)); err != nil {
+			logr.Error(err, "failed to patch resource")
+		}
+		if err := r.Status().Patch(ctx, list.DeepCopy(), client.MergeFrom(listOriginal)); err != nil {
+			logr.Error(err, "failed to patch resource status")
+		}
+	}()
+
+	if list.DeletionTimestamp == nil && !controllerutil.ContainsFinalizer(list, FinalizerName) {
+		controllerutil.AddFinalizer(list, FinalizerName)
+	}
+
+	// skip all calls to MailU, including the deletion, until the annotation is removed
+	if paused(list) {
+		meta.SetStatusCondition(&list.Status.Conditions, getPausedCondition())
+		logr.Info("reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&list.Status.Conditions, ConditionTypePaused)
+
+	// only the oldest resource targeting an object in MailU manages it, the others must not call MailU
+	winner, err := findConflictWinner(ctx, r.Client, "MailingList", list)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if winner != "" {
+		msg := fmt.Sprintf("%s is already managed by %s", list.Spec.Name+"@"+list.Spec.Domain, winner)
+		meta.SetStatusCondition(&list.Status.Conditions, getConflictCondition(msg))
+		meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionFalse, "Conflict", msg))
+		logr.Info("conflicting resource, skipping reconciliation", "winner", winner)
+		if listOriginal.DeletionTimestamp != nil {
+			controllerutil.RemoveFinalizer(list, FinalizerName)
+		}
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&list.Status.Conditions, ConditionTypeConflict)
+
+	result, err := r.reconcile(ctx, list)
+	if err != nil {
+		return result, err
+	}
+
+	if listOriginal.DeletionTimestamp != nil && result.RequeueAfter == 0 {
+		controllerutil.RemoveFinalizer(list, FinalizerName)
+	}
+
+	return result, nil
+}
+
+func (r *MailingListReconciler) reconcile(ctx context.Context, list *operatorv1alpha1.MailingList) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if r.ApiClient == nil {
+		api, err := mailu.NewClient(r.ApiURL, mailu.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			req.Header.Add("Authorization", "Bearer "+r.ApiToken)
+			return nil
+		}))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.ApiClient = api
+	}
+
+	if !observeOnly(list, r.ObserveOnly) {
+		meta.RemoveStatusCondition(&list.Status.Conditions, ConditionTypeObserveOnly)
+	}
+
+	if list.DeletionTimestamp != nil && deletionPolicy(list.Spec.DeletionPolicy, r.DeletionPolicy) == operatorv1alpha1.DeletionPolicyRetain {
+		return r.retain(ctx, list)
+	}
+
+	email := list.Spec.Name + "@" + list.Spec.Domain
+
+	foundAlias, retry, err := getAlias(ctx, r.ApiClient, email)
+	if err != nil {
+		if retry {
+			logr.Info(fmt.Errorf("failed to get alias, requeueing: %w", err).Error())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		// we explicitly set the error in the status only on a permanent (non-retryable) error
+		meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		logr.Error(err, "failed to get alias")
+		return ctrl.Result{}, nil
+	}
+
+	if list.DeletionTimestamp != nil {
+		if foundAlias == nil || (list.Status.ObservedGeneration == 0 && !ownedBy(foundAlias.Comment, list)) {
+			// no need to delete it, if it does not exist or was never applied by this resource
+			return ctrl.Result{}, nil
+		}
+		return r.delete(ctx, list, email)
+	}
+
+	members, err := r.listMembers(ctx, list)
+	if err != nil {
+		logr.Info(fmt.Errorf("failed to list members, requeueing: %w", err).Error())
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	if foundAlias == nil {
+		if result, wait := waitForDomain(ctx, r.Client, r.ApiClient, list, &list.Status.Conditions, MailingListConditionTypeReady, resyncInterval(list, r.ResyncInterval)); wait {
+			return result, nil
+		}
+	} else if list.Status.ObservedGeneration == 0 && !ownedBy(foundAlias.Comment, list) {
+		// the members of an existing alias cannot be told apart from its other destinations, so it is never adopted
+		msg := fmt.Sprintf("Alias %s already exists in MailU and is not managed by this mailing list", email)
+		meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionFalse, "AlreadyExists", msg))
+		logr.Info("not adopting existing alias: " + msg)
+		return ctrl.Result{}, nil
+	}
+
+	return r.apply(ctx, list, foundAlias, members)
+}
+
+// apply creates or updates the alias of the mailing list with the members and extra destinations.
+func (r *MailingListReconciler) apply(ctx context.Context, list *operatorv1alpha1.MailingList, apiAlias *mailu.Alias, members []string) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	destinations := slices.Clone(members)
+	for _, d := range list.Spec.ExtraDestinations {
+		if !slices.Contains(destinations, d) {
+			destinations = append(destinations, d)
+		}
+	}
+	comment := withOwnerMarker(list.Spec.Comment, list)
+	wildcard := false
+	newAlias := mailu.Alias{
+		Email:       list.Spec.Name + "@" + list.Spec.Domain,
+		Comment:     &comment,
+		Destination: &destinations,
+		Wildcard:    &wildcard,
+	}
+
+	var retry bool
+	var err error
+	var reason, message string
+	if apiAlias == nil {
+		if observeOnly(list, r.ObserveOnly) {
+			reportPlan(r.Recorder, list, &list.Status.Conditions, "Create", "Would create alias "+newAlias.Email+" in MailU")
+			logr.Info("observe-only, not creating alias of mailing list")
+			return ctrl.Result{RequeueAfter: resyncInterval(list, r.ResyncInterval)}, nil
+		}
+		retry, err = createAlias(ctx, r.ApiClient, newAlias)
+		reason, message = "Created", "Alias created in MailU"
+	} else {
+		jsonNew, _ := json.Marshal(newAlias) //nolint:errcheck
+		jsonOld, _ := json.Marshal(apiAlias) //nolint:errcheck
+		if reflect.DeepEqual(jsonNew, jsonOld) {
+			meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionTrue, "Updated", "Alias updated in MailU"))
+			list.Status.ObservedGeneration = list.Generation
+			list.Status.Members = members
+			if observeOnly(list, r.ObserveOnly) {
+				reportPlan(r.Recorder, list, &list.Status.Conditions, "None", "Alias is up to date in MailU")
+			}
+			logr.Info("alias of mailing list is up to date, no change needed")
+			return ctrl.Result{RequeueAfter: resyncInterval(list, r.ResyncInterval)}, nil
+		}
+		if observeOnly(list, r.ObserveOnly) {
+			fields := strings.Join(diffFields(newAlias, *apiAlias, aliasFields), ", ")
+			reportPlan(r.Recorder, list, &list.Status.Conditions, "Update", "Would update alias "+newAlias.Email+" in MailU: "+fields)
+			logr.Info("observe-only, not updating alias of mailing list", "fields", fields)
+			return ctrl.Result{RequeueAfter: resyncInterval(list, r.ResyncInterval)}, nil
+		}
+		retry, err = updateAlias(ctx, r.ApiClient, newAlias)
+		reason, message = "Updated", "Alias updated in MailU"
+	}
+	if err != nil {
+		meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		if retry {
+			logr.Info(fmt.Errorf("failed to apply alias of mailing list, requeueing: %w", err).Error())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		logr.Error(err, "failed to apply alias of mailing list")
+		return ctrl.Result{}, err
+	}
+	if retry {
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	if !slices.Equal(list.Status.Members, members) {
+		recordEvent(r.Recorder, list, corev1.EventTypeNormal, "MembersChanged", reason, "Mailing list has %d members", len(members))
+	}
+	meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionTrue, reason, message))
+	list.Status.ObservedGeneration = list.Generation
+	list.Status.Members = members
+	logr.Info("applied alias of mailing list", "members", len(members))
+
+	return ctrl.Result{RequeueAfter: resyncInterval(list, r.ResyncInterval)}, nil
+}
+
+func (r *MailingListReconciler) delete(ctx context.Context, list *operatorv1alpha1.MailingList, email string) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if observeOnly(list, r.ObserveOnly) {
+		reportPlan(r.Recorder, list, &list.Status.Conditions, "Delete", "Would delete alias "+email+" in MailU")
+		logr.Info("observe-only, not deleting alias of mailing list")
+		return ctrl.Result{}, nil
+	}
+
+	retry, err := deleteAlias(ctx, r.ApiClient, email)
+	if err != nil {
+		meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		if retry {
+			logr.Info(fmt.Errorf("failed to delete alias of mailing list, requeueing: %w", err).Error())
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		logr.Error(err, "failed to delete alias of mailing list")
+		return ctrl.Result{}, err
+	}
+
+	if retry {
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	logr.Info("deleted alias of mailing list")
+
+	return ctrl.Result{}, nil
+}
+
+// listMembers returns the sorted addresses of the ready Users in the namespace of the mailing list that match its
+// selector.
+func (r *MailingListReconciler) listMembers(ctx context.Context, list *operatorv1alpha1.MailingList) ([]string, error) {
+	members := []string{}
+	if list.Spec.Selector == nil {
+		return members, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(list.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	users := &operatorv1alpha1.UserList{}
+	if err := r.List(ctx, users, client.InNamespace(list.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	for _, u := range users.Items {
+		if u.DeletionTimestamp != nil || !meta.IsStatusConditionTrue(u.Status.Conditions, UserConditionTypeReady) {
+			continue
+		}
+		address := u.Spec.Name + "@" + u.Spec.Domain
+		if !slices.Contains(members, address) {
+			members = append(members, address)
+		}
+	}
+	slices.Sort(members)
+	return members, nil
+}
+
+// enqueueSelectingMailingLists returns a handler enqueueing all MailingLists in the namespace of the changed User
+// whose selector matches it, so joining and leaving members are applied.
+func enqueueSelectingMailingLists(c client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(mapSelectingMailingLists(c))
+}
+
+func mapSelectingMailingLists(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		lists := &operatorv1alpha1.MailingListList{}
+		if err := c.List(ctx, lists, client.InNamespace(obj.GetNamespace())); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list mailing lists")
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, l := range lists.Items {
+			if l.Spec.Selector == nil {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(l.Spec.Selector)
+			if err != nil || !selector.Matches(labels.Set(obj.GetLabels())) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: l.Namespace, Name: l.Name},
+			})
+		}
+		return requests
+	}
+}
+
+func getMailingListReadyCondition(status metav1.ConditionStatus, reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    MailingListConditionTypeReady,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MailingListReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&operatorv1alpha1.MailingList{}).
+		Watches(&operatorv1alpha1.User{}, enqueueSelectingMailingLists(mgr.GetClient())).
+		Watches(&operatorv1alpha1.User{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.MailingListList{})).
+		Watches(&operatorv1alpha1.Alias{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.MailingListList{})).
+		Watches(&operatorv1alpha1.MailingList{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.MailingListList{})).
+		Watches(&operatorv1alpha1.Domain{}, enqueueWaitingForDomain(mgr.GetClient(), &operatorv1alpha1.MailingListList{})).
+		Complete(reconcile.AsReconciler(r.Client, r))
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func Test_mapSelectingMailingLists(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	team := &operatorv1alpha1.MailingList{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"},
+		Spec: operatorv1alpha1.MailingListSpec{Name: "team", Domain: "example.com",
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "mail"}}},
+	}
+	other := &operatorv1alpha1.MailingList{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"},
+		Spec: operatorv1alpha1.MailingListSpec{Name: "other", Domain: "example.com",
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "mail"}}},
+	}
+	static := &operatorv1alpha1.MailingList{
+		ObjectMeta: metav1.ObjectMeta{Name: "static", Namespace: "default"},
+		Spec:       operatorv1alpha1.MailingListSpec{Name: "static", Domain: "example.com", ExtraDestinations: []string{"a@example.com"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(team, other, static).Build()
+	mapFunc := mapSelectingMailingLists(c)
+
+	user := &operatorv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "default", Labels: map[string]string{"team": "mail"}}}
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "team"}}}
+	if got := mapFunc(context.Background(), user); !reflect.DeepEqual(got, want) {
+		t.Errorf("mapSelectingMailingLists() = %v, want %v", got, want)
+	}
+
+	user.Labels = nil
+	if got := mapFunc(context.Background(), user); len(got) != 0 {
+		t.Errorf("mapSelectingMailingLists() = %v for a user without labels", got)
+	}
+}
@@ -0,0 +1,134 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	. "github.com/sickhub/mailu-operator/internal/controller"
+)
+
+var _ = Describe("MailingList Controller", func() {
+	var (
+		controllerReconciler   *MailingListReconciler
+		res                    *operatorv1alpha1.MailingList
+		resAfterReconciliation *operatorv1alpha1.MailingList
+		member                 *operatorv1alpha1.User
+	)
+	ctx := context.Background()
+
+	reconcile := func(deleted bool) (ctrl.Result, error) {
+		Expect(res).NotTo(BeNil())
+		Expect(controllerReconciler).NotTo(BeNil())
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      res.GetName(),
+			Namespace: res.GetNamespace(),
+		}
+		result, resultErr := controllerReconciler.Reconcile(ctx, res)
+
+		resAfterReconciliation = &operatorv1alpha1.MailingList{}
+		err := k8sClient.Get(ctx, typeNamespacedName, resAfterReconciliation)
+		if !deleted {
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		return result, resultErr
+	}
+
+	// aliasOf returns the alias in MailU of the mailing list with the given destinations.
+	aliasOf := func(list *operatorv1alpha1.MailingList, destinations ...string) *operatorv1alpha1.Alias {
+		return &operatorv1alpha1.Alias{
+			ObjectMeta: *list.ObjectMeta.DeepCopy(),
+			Spec: operatorv1alpha1.AliasSpec{
+				Name: list.Spec.Name, Domain: list.Spec.Domain, Comment: list.Spec.Comment, Destination: destinations,
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		mock = ghttp.NewServer()
+
+		Expect(k8sClient).NotTo(BeNil())
+		controllerReconciler = &MailingListReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+			ApiURL: mock.URL(),
+		}
+	})
+
+	Context("On an empty cluster", Ordered, func() {
+
+		When("creating a MailingList", func() {
+			BeforeAll(func() {
+				member = CreateResource(operatorv1alpha1.User{}, "member", mockDomain).(*operatorv1alpha1.User)
+				member.Labels = map[string]string{"list": "team"}
+				Expect(k8sClient.Create(ctx, member)).To(Succeed())
+				meta.SetStatusCondition(&member.Status.Conditions, metav1.Condition{Type: UserConditionTypeReady, Status: metav1.ConditionTrue, Reason: "Created"})
+				Expect(k8sClient.Status().Update(ctx, member)).To(Succeed())
+
+				res = CreateResource(operatorv1alpha1.MailingList{}, "team", mockDomain).(*operatorv1alpha1.MailingList)
+				Expect(k8sClient.Create(ctx, res)).To(Succeed())
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)).To(Succeed())
+			})
+
+			It("creates the alias with the ready members", func() {
+				prepareFindAlias(aliasOf(res), http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
+				prepareCreateAlias(aliasOf(res, "member@"+mockDomain), http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, MailingListConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.Members).To(Equal([]string{"member@" + mockDomain}))
+			})
+
+			It("removes a user that no longer matches the selector", func() {
+				member.Labels = nil
+				Expect(k8sClient.Update(ctx, member)).To(Succeed())
+
+				res = resAfterReconciliation.DeepCopy()
+				prepareFindAlias(aliasOf(res, "member@"+mockDomain), http.StatusOK)
+				preparePatchAlias(aliasOf(res), http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, MailingListConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.Members).To(BeEmpty())
+			})
+		})
+
+		When("deleting a MailingList", func() {
+			BeforeAll(func() {
+				res = resAfterReconciliation.DeepCopy()
+				Expect(k8sClient.Delete(ctx, res)).To(Succeed())
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)).To(Succeed())
+			})
+
+			AfterAll(func() {
+				Expect(k8sClient.Delete(ctx, member)).To(Succeed())
+			})
+
+			It("deletes the alias", func() {
+				prepareFindAlias(aliasOf(res), http.StatusOK)
+				prepareDeleteAlias(aliasOf(res), http.StatusOK)
+
+				_, err := reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.MailingList{}))
+			})
+		})
+	})
+})
@@ -2,6 +2,7 @@ package controller_test
 
 import (
 	"net/http"
+	"strings"
 
 	openapitypes "github.com/oapi-codegen/runtime/types"
 	. "github.com/onsi/gomega/ghttp"
@@ -43,6 +44,13 @@ func CreateResource(obj interface{}, name, domain string) client.Object {
 			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
 			Spec:       operatorv1alpha1.UserSpec{Name: name, Domain: domain, RawPassword: name + "-password"},
 		}
+	case operatorv1alpha1.MailingList:
+		return &operatorv1alpha1.MailingList{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: operatorv1alpha1.MailingListSpec{
+				Name: name, Domain: domain, Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"list": name}},
+			},
+		}
 	case operatorv1alpha1.Domain:
 		return &operatorv1alpha1.Domain{
 			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
@@ -53,6 +61,19 @@ func CreateResource(obj interface{}, name, domain string) client.Object {
 	}
 }
 
+// ownerComment returns the comment as the operator stamps it into MailU: with the ownership
+// marker of the given resource, if it has already been created in Kubernetes.
+func ownerComment(obj metav1.Object, comment string) *string {
+	if obj.GetUID() == "" {
+		return &comment
+	}
+	marker := "[mailu-operator " + obj.GetNamespace() + "/" + obj.GetName() + " " + string(obj.GetUID()) + "]"
+	if comment != "" {
+		marker = comment + " " + marker
+	}
+	return &marker
+}
+
 func getResponse(status int) http.HandlerFunc {
 	switch status {
 	case http.StatusForbidden:
@@ -82,7 +103,7 @@ func prepareFindAlias(alias *operatorv1alpha1.Alias, status int) {
 	if status == http.StatusOK {
 		response = RespondWithJSONEncoded(http.StatusOK, mailu.Alias{
 			Email:       alias.Spec.Name + "@" + alias.Spec.Domain,
-			Comment:     &alias.Spec.Comment,
+			Comment:     ownerComment(alias, alias.Spec.Comment),
 			Destination: &alias.Spec.Destination,
 			Wildcard:    &alias.Spec.Wildcard,
 		})
@@ -98,7 +119,7 @@ func prepareCreateAlias(alias *operatorv1alpha1.Alias, status int) {
 		VerifyRequest("POST", "/alias"),
 		VerifyJSONRepresenting(mailu.Alias{
 			Email:       alias.Spec.Name + "@" + alias.Spec.Domain,
-			Comment:     &alias.Spec.Comment,
+			Comment:     ownerComment(alias, alias.Spec.Comment),
 			Destination: &alias.Spec.Destination,
 			Wildcard:    &alias.Spec.Wildcard,
 		}),
@@ -121,13 +142,36 @@ func prepareDeleteAlias(alias *operatorv1alpha1.Alias, status int) {
 }
 
 // Domain
+// prepareValidateDestinations lets the destinations of an Alias be found as users in MailU (StatusOK), or neither as
+// users nor as aliases (StatusNotFound). Their domains exist in MailU.
+func prepareValidateDestinations(status int, addresses ...string) {
+	domains := map[string]bool{}
+	for _, address := range addresses {
+		domain := address[strings.Index(address, "@")+1:]
+		if !domains[domain] {
+			prepareDomainExists(domain, http.StatusOK)
+			domains[domain] = true
+		}
+		mock.AppendHandlers(CombineHandlers(
+			VerifyRequest("GET", "/user/"+address),
+			getResponse(status),
+		))
+		if status == http.StatusNotFound {
+			mock.AppendHandlers(CombineHandlers(
+				VerifyRequest("GET", "/alias/"+address),
+				getResponse(status),
+			))
+		}
+	}
+}
+
 func prepareFindDomain(domain *operatorv1alpha1.Domain, status int) {
 	response := getResponse(status)
 	if status == http.StatusOK {
 		response = RespondWithJSONEncoded(http.StatusOK, mailu.Domain{
 			Name:          domain.Spec.Name,
 			Alternatives:  &domain.Spec.Alternatives,
-			Comment:       &domain.Spec.Comment,
+			Comment:       ownerComment(domain, domain.Spec.Comment),
 			MaxAliases:    &domain.Spec.MaxAliases,
 			MaxQuotaBytes: &domain.Spec.MaxQuotaBytes,
 			MaxUsers:      &domain.Spec.MaxUsers,
@@ -140,12 +184,17 @@ func prepareFindDomain(domain *operatorv1alpha1.Domain, status int) {
 	))
 }
 
+// prepareDomainExists lets the domain of a User or Alias exist in MailU.
+func prepareDomainExists(domain string, status int) {
+	prepareFindDomain(CreateResource(operatorv1alpha1.Domain{}, domain, domain).(*operatorv1alpha1.Domain), status)
+}
+
 func prepareCreateDomain(domain *operatorv1alpha1.Domain, status int) {
 	mock.AppendHandlers(CombineHandlers(
 		VerifyRequest("POST", "/domain"),
 		VerifyJSONRepresenting(mailu.Domain{
 			Name:          domain.Spec.Name,
-			Comment:       &domain.Spec.Comment,
+			Comment:       ownerComment(domain, domain.Spec.Comment),
 			MaxAliases:    &domain.Spec.MaxAliases,
 			MaxQuotaBytes: &domain.Spec.MaxQuotaBytes,
 			MaxUsers:      &domain.Spec.MaxUsers,
@@ -162,6 +211,21 @@ func preparePatchDomain(domain *operatorv1alpha1.Domain, status int) {
 	))
 }
 
+func prepareListDomainUsers(domain *operatorv1alpha1.Domain, status int, emails ...string) {
+	response := getResponse(status)
+	if status == http.StatusOK {
+		users := []mailu.User{}
+		for _, email := range emails {
+			users = append(users, mailu.User{Email: email})
+		}
+		response = RespondWithJSONEncoded(http.StatusOK, users)
+	}
+	mock.AppendHandlers(CombineHandlers(
+		VerifyRequest("GET", "/domain/"+domain.Spec.Name+"/users"),
+		response,
+	))
+}
+
 func prepareDeleteDomain(domain *operatorv1alpha1.Domain, status int) {
 	mock.AppendHandlers(CombineHandlers(
 		VerifyRequest("DELETE", "/domain/"+domain.Spec.Name),
@@ -170,31 +234,50 @@ func prepareDeleteDomain(domain *operatorv1alpha1.Domain, status int) {
 }
 
 // User
+// settingsOf returns the settings of the user as applied to MailU, if its Domain has no user defaults.
+func settingsOf(user *operatorv1alpha1.User) operatorv1alpha1.UserSettings {
+	disabled, unlimited, threshold := false, int64(-1), 0
+	settings := *user.Spec.UserSettings.DeepCopy()
+	for _, flag := range []**bool{&settings.AllowSpoofing, &settings.EnableIMAP, &settings.EnablePOP, &settings.SpamEnabled, &settings.SpamMarkAsRead} {
+		if *flag == nil {
+			*flag = &disabled
+		}
+	}
+	if settings.QuotaBytes == nil {
+		settings.QuotaBytes = &unlimited
+	}
+	if settings.SpamThreshold == nil {
+		settings.SpamThreshold = &threshold
+	}
+	return settings
+}
+
 func prepareFindUser(user *operatorv1alpha1.User, status int) {
 	response := getResponse(status)
 	if status == http.StatusOK {
+		settings := settingsOf(user)
 		newUser := mailu.User{
-			AllowSpoofing:      &user.Spec.AllowSpoofing,
+			AllowSpoofing:      settings.AllowSpoofing,
 			ChangePwNextLogin:  &user.Spec.ChangePassword,
-			Comment:            &user.Spec.Comment,
+			Comment:            ownerComment(user, user.Spec.Comment),
 			DisplayedName:      &user.Spec.DisplayedName,
 			Email:              user.Spec.Name + "@" + user.Spec.Domain,
 			Enabled:            &user.Spec.Enabled,
-			EnableImap:         &user.Spec.EnableIMAP,
-			EnablePop:          &user.Spec.EnablePOP,
+			EnableImap:         settings.EnableIMAP,
+			EnablePop:          settings.EnablePOP,
 			ForwardEnabled:     &user.Spec.ForwardEnabled,
 			ForwardDestination: &user.Spec.ForwardDestination,
 			ForwardKeep:        &user.Spec.ForwardKeep,
 			GlobalAdmin:        &user.Spec.GlobalAdmin,
 			Password:           &user.Spec.Name,
-			QuotaBytes:         &user.Spec.QuotaBytes,
-			QuotaBytesUsed:     &user.Spec.QuotaBytes,
+			QuotaBytes:         settings.QuotaBytes,
+			QuotaBytesUsed:     settings.QuotaBytes,
 			ReplyBody:          &user.Spec.ReplyBody,
 			ReplyEnabled:       &user.Spec.ReplyEnabled,
 			ReplySubject:       &user.Spec.ReplySubject,
-			SpamEnabled:        &user.Spec.SpamEnabled,
-			SpamMarkAsRead:     &user.Spec.SpamMarkAsRead,
-			SpamThreshold:      &user.Spec.SpamThreshold,
+			SpamEnabled:        settings.SpamEnabled,
+			SpamMarkAsRead:     settings.SpamMarkAsRead,
+			SpamThreshold:      settings.SpamThreshold,
 		}
 		if user.Spec.ReplyEndDate != "" {
 			d := &openapitypes.Date{}
@@ -222,27 +305,28 @@ func prepareFindUser(user *operatorv1alpha1.User, status int) {
 }
 
 func prepareCreateUser(user *operatorv1alpha1.User, status int) {
+	settings := settingsOf(user)
 	newUser := mailu.User{
 		Email:              user.Spec.Name + "@" + user.Spec.Domain,
-		AllowSpoofing:      &user.Spec.AllowSpoofing,
+		AllowSpoofing:      settings.AllowSpoofing,
 		ChangePwNextLogin:  &user.Spec.ChangePassword,
-		Comment:            &user.Spec.Comment,
+		Comment:            ownerComment(user, user.Spec.Comment),
 		DisplayedName:      &user.Spec.DisplayedName,
-		EnableImap:         &user.Spec.EnableIMAP,
-		EnablePop:          &user.Spec.EnablePOP,
+		EnableImap:         settings.EnableIMAP,
+		EnablePop:          settings.EnablePOP,
 		Enabled:            &user.Spec.Enabled,
 		ForwardDestination: &user.Spec.ForwardDestination,
 		ForwardEnabled:     &user.Spec.ForwardEnabled,
 		ForwardKeep:        &user.Spec.ForwardKeep,
 		GlobalAdmin:        &user.Spec.GlobalAdmin,
-		QuotaBytes:         &user.Spec.QuotaBytes,
+		QuotaBytes:         settings.QuotaBytes,
 		RawPassword:        &user.Spec.RawPassword,
 		ReplyBody:          &user.Spec.ReplyBody,
 		ReplyEnabled:       &user.Spec.ReplyEnabled,
 		ReplySubject:       &user.Spec.ReplySubject,
-		SpamEnabled:        &user.Spec.SpamEnabled,
-		SpamMarkAsRead:     &user.Spec.SpamMarkAsRead,
-		SpamThreshold:      &user.Spec.SpamThreshold,
+		SpamEnabled:        settings.SpamEnabled,
+		SpamMarkAsRead:     settings.SpamMarkAsRead,
+		SpamThreshold:      settings.SpamThreshold,
 	}
 	if user.Spec.ReplyEndDate != "" {
 		d := &openapitypes.Date{}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+// applyOwnedAlias creates or updates an alias in MailU on behalf of the owner, e.g. the aliases of a user or the role
+// aliases of a domain. It returns the state of the alias, a message if it is not applied, and if a retryable error
+// occurred. An existing alias not created by the owner is left untouched.
+func applyOwnedAlias(ctx context.Context, api *mailu.Client, owner metav1.Object, address string, destination []string, wildcard bool) (operatorv1alpha1.UserAliasState, string, bool) {
+	foundAlias, retry, err := getAlias(ctx, api, address)
+	if err != nil {
+		return operatorv1alpha1.UserAliasStateError, err.Error(), retry
+	}
+	if foundAlias != nil && !ownedBy(foundAlias.Comment, owner) {
+		return operatorv1alpha1.UserAliasStateConflict, "Alias exists in MailU and is not managed by this resource", false
+	}
+
+	newAlias := ownedAlias(owner, address, destination, wildcard)
+	if foundAlias != nil && len(diffFields(newAlias, *foundAlias, aliasFields)) == 0 {
+		return operatorv1alpha1.UserAliasStateApplied, "", false
+	}
+
+	if foundAlias == nil {
+		retry, err = createAlias(ctx, api, newAlias)
+	} else {
+		retry, err = updateAlias(ctx, api, newAlias)
+	}
+	if err != nil {
+		return operatorv1alpha1.UserAliasStateError, "failed to apply alias: " + err.Error(), retry
+	}
+	if retry {
+		return operatorv1alpha1.UserAliasStateError, "alias is not applied yet", true
+	}
+	return operatorv1alpha1.UserAliasStateApplied, "", false
+}
+
+// planOwnedAlias returns the action applyOwnedAlias would take for the alias, "Create" or "Update", or an empty string
+// if the alias is up to date or not created by the owner. It only reads from MailU.
+func planOwnedAlias(ctx context.Context, api *mailu.Client, owner metav1.Object, address string, destination []string, wildcard bool) (string, bool, error) {
+	foundAlias, retry, err := getAlias(ctx, api, address)
+	if err != nil || retry {
+		return "", retry, err
+	}
+	if foundAlias == nil {
+		return "Create", false, nil
+	}
+	if !ownedBy(foundAlias.Comment, owner) || len(diffFields(ownedAlias(owner, address, destination, wildcard), *foundAlias, aliasFields)) == 0 {
+		return "", false, nil
+	}
+	return "Update", false, nil
+}
+
+// aliasPlan describes the planned changes of owned aliases, e.g. "create a@example.com, delete b@example.com", or
+// returns an empty string if nothing would change.
+func aliasPlan(create, update, remove []string) string {
+	plan := []string{}
+	if len(create) > 0 {
+		plan = append(plan, "create "+strings.Join(create, ", "))
+	}
+	if len(update) > 0 {
+		plan = append(plan, "update "+strings.Join(update, ", "))
+	}
+	if len(remove) > 0 {
+		plan = append(plan, "delete "+strings.Join(remove, ", "))
+	}
+	return strings.Join(plan, "; ")
+}
+
+func ownedAlias(owner metav1.Object, address string, destination []string, wildcard bool) mailu.Alias {
+	comment := withOwnerMarker("", owner)
+	return mailu.Alias{
+		Email:       address,
+		Comment:     &comment,
+		Destination: &destination,
+		Wildcard:    &wildcard,
+	}
+}
+
+func deleteAliasMessage(err error) string {
+	if err == nil {
+		return "alias is not deleted yet"
+	}
+	return "failed to delete alias: " + err.Error()
+}
@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+var (
+	// orphanedObjects is the number of objects in MailU without a resource, found by the last pruning run.
+	orphanedObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mailu_operator_orphaned_objects",
+		Help: "Number of objects in MailU without a resource, found by the last pruning run",
+	}, []string{"kind"})
+
+	// prunedObjects is the number of orphaned objects deleted in MailU.
+	prunedObjects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mailu_operator_pruned_objects_total",
+		Help: "Number of orphaned objects deleted in MailU",
+	}, []string{"kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedObjects, prunedObjects)
+}
+
+// orphan is an object in MailU without a resource.
+type orphan struct {
+	Kind    string
+	Name    string
+	Comment *string
+}
+
+// Pruner periodically compares all objects in MailU with the resources and reports the orphans.
+// If Delete is set, orphans carrying the ownership marker or matching the Selector are deleted in MailU.
+type Pruner struct {
+	client.Client
+	ApiURL      string
+	ApiToken    string
+	ApiClient   *mailu.Client
+	Interval    time.Duration
+	Delete      bool
+	Selector    string
+	ObserveOnly bool
+	// Namespaces are the namespaces watched by the operator. Orphans carrying the ownership marker of a resource in
+	// another namespace are never deleted, as that resource is unknown to the operator.
+	Namespaces []string
+}
+
+// SetupWithManager adds the Pruner to the Manager, if an interval is set.
+func (p *Pruner) SetupWithManager(mgr ctrl.Manager) error {
+	if p.Interval <= 0 {
+		return nil
+	}
+	return mgr.Add(p)
+}
+
+// NeedLeaderElection makes sure only one instance of the operator prunes MailU.
+func (p *Pruner) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs the pruning loop until the context is cancelled.
+func (p *Pruner) Start(ctx context.Context) error {
+	logr := log.FromContext(ctx).WithName("pruner")
+	ctx = log.IntoContext(ctx, logr)
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.prune(ctx); err != nil {
+				logr.Error(err, "failed to prune MailU")
+			}
+		}
+	}
+}
+
+func (p *Pruner) prune(ctx context.Context) error {
+	logr := log.FromContext(ctx)
+
+	if p.ApiClient == nil {
+		api, err := mailu.NewClient(p.ApiURL, mailu.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			req.Header.Add("Authorization", "Bearer "+p.ApiToken)
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+		p.ApiClient = api
+	}
+
+	managed, err := p.managedObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	aliases, err := listObjects[mailu.Alias](p.ApiClient.ListAlias(ctx))
+	if err != nil {
+		return err
+	}
+	users, err := listObjects[mailu.User](p.ApiClient.ListUser(ctx))
+	if err != nil {
+		return err
+	}
+	domains, err := listObjects[mailu.Domain](p.ApiClient.ListDomain(ctx))
+	if err != nil {
+		return err
+	}
+
+	// aliases and users are pruned before their domains
+	orphans := findOrphans("Alias", aliases, func(a mailu.Alias) (string, *string) { return a.Email, a.Comment }, managed["Alias"])
+	orphans = append(orphans, findOrphans("User", users, func(u mailu.User) (string, *string) { return u.Email, u.Comment }, managed["User"])...)
+	orphans = append(orphans, findOrphans("Domain", domains, func(d mailu.Domain) (string, *string) { return d.Name, d.Comment }, managed["Domain"])...)
+
+	counts := map[string]int{"Alias": 0, "User": 0, "Domain": 0}
+	for _, o := range orphans {
+		counts[o.Kind]++
+		logr.Info("found orphaned object in MailU", "kind", o.Kind, "name", o.Name)
+
+		if !p.prunable(o, managed) {
+			continue
+		}
+		if err := p.deleteObject(ctx, o); err != nil {
+			logr.Error(err, "failed to prune orphaned object in MailU", "kind", o.Kind, "name", o.Name)
+			continue
+		}
+		prunedObjects.WithLabelValues(o.Kind).Inc()
+		logr.Info("pruned orphaned object in MailU", "kind", o.Kind, "name", o.Name)
+	}
+	for kind, count := range counts {
+		orphanedObjects.WithLabelValues(kind).Set(float64(count))
+	}
+
+	return nil
+}
+
+// managedObjects returns the names of all objects in MailU that are managed by a resource, by kind. This includes the
+// addresses last applied, which are still in use while a rename is rejected or pending.
+func (p *Pruner) managedObjects(ctx context.Context) (map[string]map[string]bool, error) {
+	managed := map[string]map[string]bool{"Alias": {}, "User": {}, "Domain": {}}
+
+	aliases := &operatorv1alpha1.AliasList{}
+	if err := p.List(ctx, aliases); err != nil {
+		return nil, err
+	}
+	for _, a := range aliases.Items {
+		managed["Alias"][a.Spec.Name+"@"+a.Spec.Domain] = true
+		if a.Status.AppliedAddress != "" {
+			managed["Alias"][a.Status.AppliedAddress] = true
+		}
+	}
+
+	// mailing lists are aliases in MailU
+	lists := &operatorv1alpha1.MailingListList{}
+	if err := p.List(ctx, lists); err != nil {
+		return nil, err
+	}
+	for _, l := range lists.Items {
+		managed["Alias"][l.Spec.Name+"@"+l.Spec.Domain] = true
+	}
+
+	users := &operatorv1alpha1.UserList{}
+	if err := p.List(ctx, users); err != nil {
+		return nil, err
+	}
+	for _, u := range users.Items {
+		managed["User"][u.Spec.Name+"@"+u.Spec.Domain] = true
+		if u.Status.AppliedAddress != "" {
+			managed["User"][u.Status.AppliedAddress] = true
+		}
+		for _, alias := range userAliasAddresses(&u) {
+			managed["Alias"][alias] = true
+		}
+		for _, alias := range u.Status.Aliases {
+			managed["Alias"][alias.Address] = true
+		}
+	}
+
+	domains := &operatorv1alpha1.DomainList{}
+	if err := p.List(ctx, domains); err != nil {
+		return nil, err
+	}
+	for _, d := range domains.Items {
+		managed["Domain"][d.Spec.Name] = true
+		for _, alias := range domainAliases(&d) {
+			managed["Alias"][alias.address] = true
+		}
+		for _, alias := range d.Status.Aliases {
+			managed["Alias"][alias.Address] = true
+		}
+	}
+
+	return managed, nil
+}
+
+// prunable returns true, if the orphan may be deleted in MailU.
+func (p *Pruner) prunable(o orphan, managed map[string]map[string]bool) bool {
+	if !p.Delete || p.ObserveOnly {
+		return false
+	}
+
+	// deleting a domain in MailU deletes all its users and aliases, even if they are managed by a resource
+	if o.Kind == "Domain" {
+		for _, kind := range []string{"Alias", "User"} {
+			for name := range managed[kind] {
+				if strings.HasSuffix(name, "@"+o.Name) {
+					return false
+				}
+			}
+		}
+	}
+
+	if marker, ok := parseOwnerMarker(o.Comment); ok {
+		return slices.Contains(p.Namespaces, marker.Namespace)
+	}
+	if p.Selector == "" {
+		return false
+	}
+	matched, err := path.Match(p.Selector, o.Name)
+	return err == nil && matched
+}
+
+func (p *Pruner) deleteObject(ctx context.Context, o orphan) error {
+	var res *http.Response
+	var err error
+	switch o.Kind {
+	case "Alias":
+		res, err = p.ApiClient.DeleteAlias(ctx, o.Name)
+	case "User":
+		res, err = p.ApiClient.DeleteUser(ctx, o.Name)
+	case "Domain":
+		res, err = p.ApiClient.DeleteDomain(ctx, o.Name)
+	default:
+		return errors.New("unknown kind: " + o.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close() //nolint:errcheck
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	}
+	return errors.New("unknown status: " + strconv.Itoa(res.StatusCode))
+}
+
+// listObjects decodes the response of a list request to MailU.
+func listObjects[T any](res *http.Response, err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("unknown status: " + strconv.Itoa(res.StatusCode))
+	}
+
+	var objects []T
+	if err := json.Unmarshal(body, &objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// findOrphans returns all objects that are not managed by a resource.
+func findOrphans[T any](kind string, objects []T, key func(T) (string, *string), managed map[string]bool) []orphan {
+	orphans := []orphan{}
+	for _, obj := range objects {
+		name, comment := key(obj)
+		if !managed[name] {
+			orphans = append(orphans, orphan{Kind: kind, Name: name, Comment: comment})
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+func Test_findOrphans(t *testing.T) {
+	comment := "[mailu-operator default/foo 1234]"
+	users := []mailu.User{
+		{Email: "foo@example.com", Comment: &comment},
+		{Email: "bar@example.com"},
+	}
+	managed := map[string]bool{"bar@example.com": true}
+
+	got := findOrphans("User", users, func(u mailu.User) (string, *string) { return u.Email, u.Comment }, managed)
+	want := []orphan{{Kind: "User", Name: "foo@example.com", Comment: &comment}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findOrphans() = %v, want %v", got, want)
+	}
+}
+
+func Test_prunable(t *testing.T) {
+	marked := "some comment [mailu-operator default/foo 1234]"
+	foreign := "some comment [mailu-operator other/foo 1234]"
+	plain := "some comment"
+	managed := map[string]map[string]bool{
+		"Alias":  {},
+		"User":   {"bar@managed.com": true},
+		"Domain": {},
+	}
+
+	tests := []struct {
+		name   string
+		pruner Pruner
+		orphan orphan
+		want   bool
+	}{
+		{
+			name:   "report only",
+			pruner: Pruner{},
+			orphan: orphan{Kind: "User", Name: "foo@example.com", Comment: &marked},
+			want:   false,
+		},
+		{
+			name:   "observe only",
+			pruner: Pruner{Namespaces: []string{"default"}, Delete: true, ObserveOnly: true},
+			orphan: orphan{Kind: "User", Name: "foo@example.com", Comment: &marked},
+			want:   false,
+		},
+		{
+			name:   "ownership marker",
+			pruner: Pruner{Namespaces: []string{"default"}, Delete: true},
+			orphan: orphan{Kind: "User", Name: "foo@example.com", Comment: &marked},
+			want:   true,
+		},
+		{
+			name:   "ownership marker of an unwatched namespace",
+			pruner: Pruner{Namespaces: []string{"default"}, Delete: true, Selector: "*@example.com"},
+			orphan: orphan{Kind: "User", Name: "foo@example.com", Comment: &foreign},
+			want:   false,
+		},
+		{
+			name:   "no ownership marker",
+			pruner: Pruner{Namespaces: []string{"default"}, Delete: true},
+			orphan: orphan{Kind: "User", Name: "foo@example.com", Comment: &plain},
+			want:   false,
+		},
+		{
+			name:   "matching selector",
+			pruner: Pruner{Namespaces: []string{"default"}, Delete: true, Selector: "*@example.com"},
+			orphan: orphan{Kind: "Alias", Name: "foo@example.com", Comment: &plain},
+			want:   true,
+		},
+		{
+			name:   "not matching selector",
+			pruner: Pruner{Namespaces: []string{"default"}, Delete: true, Selector: "*@example.org"},
+			orphan: orphan{Kind: "Alias", Name: "foo@example.com", Comment: &plain},
+			want:   false,
+		},
+		{
+			name:   "domain with managed users",
+			pruner: Pruner{Namespaces: []string{"default"}, Delete: true},
+			orphan: orphan{Kind: "Domain", Name: "managed.com", Comment: &marked},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pruner.prunable(tt.orphan, managed); got != tt.want {
+				t.Errorf("prunable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_prune_retained(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	user := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "1234"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "foo", Domain: "example.com"},
+	}
+	alias := &operatorv1alpha1.Alias{
+		ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default", UID: "5678"},
+		Spec:       operatorv1alpha1.AliasSpec{Name: "bar", Domain: "example.com"},
+	}
+
+	// MailU with a user and an alias, both created by their resources
+	comments := map[string]string{
+		"/user/foo@example.com":  withOwnerMarker("some comment", user),
+		"/alias/bar@example.com": withOwnerMarker("", alias),
+	}
+	deleted := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			body := struct{ Comment string }{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			comments[r.URL.Path] = body.Comment
+			w.WriteHeader(http.StatusOK)
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			comment, ok := comments[r.URL.Path]
+			switch {
+			case r.URL.Path == "/user":
+				comment := comments["/user/foo@example.com"]
+				_ = json.NewEncoder(w).Encode([]mailu.User{{Email: "foo@example.com", Comment: &comment}})
+			case r.URL.Path == "/alias":
+				comment := comments["/alias/bar@example.com"]
+				_ = json.NewEncoder(w).Encode([]mailu.Alias{{Email: "bar@example.com", Comment: &comment}})
+			case r.URL.Path == "/domain":
+				_ = json.NewEncoder(w).Encode([]mailu.Domain{})
+			case ok && strings.HasPrefix(r.URL.Path, "/user/"):
+				_ = json.NewEncoder(w).Encode(mailu.User{Email: strings.TrimPrefix(r.URL.Path, "/user/"), Comment: &comment})
+			case ok:
+				_ = json.NewEncoder(w).Encode(mailu.Alias{Email: strings.TrimPrefix(r.URL.Path, "/alias/"), Comment: &comment})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}))
+	defer server.Close()
+	api, err := mailu.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if retry, err := releaseAlias(ctx, api, alias, "bar@example.com"); err != nil || retry {
+		t.Fatalf("releaseAlias() = %v, %v", retry, err)
+	}
+	if retry, err := releaseUser(ctx, api, user, "foo@example.com"); err != nil || retry {
+		t.Fatalf("releaseUser() = %v, %v", retry, err)
+	}
+	if got := comments["/user/foo@example.com"]; got != "some comment" {
+		t.Errorf("releaseUser() comment = %q, want %q", got, "some comment")
+	}
+
+	p := &Pruner{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), ApiClient: api, Namespaces: []string{"default"}, Delete: true}
+	if err := p.prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) > 0 {
+		t.Errorf("prune() deleted retained objects %v", deleted)
+	}
+}
+
+func Test_managedObjects(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rename of the user was rejected, it is still applied at its previous address
+	user := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "john.new", Domain: "example.com", RenamePolicy: operatorv1alpha1.RenamePolicyReject},
+		Status: operatorv1alpha1.UserStatus{AppliedAddress: "john.doe@example.com",
+			Aliases: []operatorv1alpha1.UserAliasStatus{{Address: "first.last@example.com", State: operatorv1alpha1.UserAliasStateApplied}}},
+	}
+	p := &Pruner{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(user).Build()}
+
+	managed, err := p.managedObjects(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for kind, names := range map[string][]string{
+		"User":  {"john.new@example.com", "john.doe@example.com"},
+		"Alias": {"first.last@example.com"},
+	} {
+		for _, name := range names {
+			if !managed[kind][name] {
+				t.Errorf("managedObjects() does not contain %s %s", kind, name)
+			}
+		}
+	}
+}
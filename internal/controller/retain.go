@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+// The retain functions keep the objects of a resource with deletion policy Retain in MailU. They remove the ownership
+// marker from the comments of the objects, so the pruner does not delete them as orphans of the operator once the
+// resource is gone.
+
+func (r *UserReconciler) retain(ctx context.Context, user *operatorv1alpha1.User) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	// a retained user is kept at the address it was applied with, which may differ from the spec
+	address := user.Spec.Name + "@" + user.Spec.Domain
+	if user.Status.AppliedAddress != "" {
+		address = user.Status.AppliedAddress
+	}
+	if observeOnly(user, r.ObserveOnly) {
+		reportPlan(r.Recorder, user, &user.Status.Conditions, "Retain", "Would retain user "+address+" in MailU")
+		logr.Info("observe-only, not releasing user")
+		return ctrl.Result{}, nil
+	}
+
+	retry, err := releaseAliases(ctx, r.ApiClient, user, appliedUserAliases(user))
+	if err == nil && !retry {
+		retry, err = releaseUser(ctx, r.ApiClient, user, address)
+	}
+	if err != nil {
+		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+	}
+	if err != nil || retry {
+		return releaseFailed(ctx, "user", retry, err)
+	}
+
+	recordEvent(r.Recorder, user, corev1.EventTypeNormal, "Orphaned", "Retain", "User %s was retained in MailU", address)
+	logr.Info("retaining user in MailU")
+	return ctrl.Result{}, nil
+}
+
+func (r *AliasReconciler) retain(ctx context.Context, alias *operatorv1alpha1.Alias) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	// a retained alias is kept at the address it was applied with, which may differ from the spec
+	address := alias.Spec.Name + "@" + alias.Spec.Domain
+	if alias.Status.AppliedAddress != "" {
+		address = alias.Status.AppliedAddress
+	}
+	if observeOnly(alias, r.ObserveOnly) {
+		reportPlan(r.Recorder, alias, &alias.Status.Conditions, "Retain", "Would retain alias "+address+" in MailU")
+		logr.Info("observe-only, not releasing alias")
+		return ctrl.Result{}, nil
+	}
+
+	retry, err := releaseAlias(ctx, r.ApiClient, alias, address)
+	if err != nil {
+		meta.SetStatusCondition(&alias.Status.Conditions, getAliasReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+	}
+	if err != nil || retry {
+		return releaseFailed(ctx, "alias", retry, err)
+	}
+
+	recordEvent(r.Recorder, alias, corev1.EventTypeNormal, "Orphaned", "Retain", "Alias %s was retained in MailU", address)
+	logr.Info("retaining alias in MailU")
+	return ctrl.Result{}, nil
+}
+
+func (r *MailingListReconciler) retain(ctx context.Context, list *operatorv1alpha1.MailingList) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	email := list.Spec.Name + "@" + list.Spec.Domain
+	if observeOnly(list, r.ObserveOnly) {
+		reportPlan(r.Recorder, list, &list.Status.Conditions, "Retain", "Would retain alias "+email+" in MailU")
+		logr.Info("observe-only, not releasing alias of mailing list")
+		return ctrl.Result{}, nil
+	}
+
+	retry, err := releaseAlias(ctx, r.ApiClient, list, email)
+	if err != nil {
+		meta.SetStatusCondition(&list.Status.Conditions, getMailingListReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+	}
+	if err != nil || retry {
+		return releaseFailed(ctx, "alias of mailing list", retry, err)
+	}
+
+	recordEvent(r.Recorder, list, corev1.EventTypeNormal, "Orphaned", "Retain", "Alias %s was retained in MailU", email)
+	logr.Info("retaining alias of mailing list in MailU")
+	return ctrl.Result{}, nil
+}
+
+func (r *DomainReconciler) retain(ctx context.Context, domain *operatorv1alpha1.Domain) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if observeOnly(domain, r.ObserveOnly) {
+		reportPlan(r.Recorder, domain, &domain.Status.Conditions, "Retain", "Would retain domain "+domain.Spec.Name+" in MailU")
+		logr.Info("observe-only, not releasing domain")
+		return ctrl.Result{}, nil
+	}
+
+	addresses := []string{}
+	for _, state := range domain.Status.Aliases {
+		if state.State != operatorv1alpha1.UserAliasStateConflict {
+			addresses = append(addresses, state.Address)
+		}
+	}
+	retry, err := releaseAliases(ctx, r.ApiClient, domain, addresses)
+	if err == nil && !retry {
+		retry, err = r.releaseDomain(ctx, domain)
+	}
+	if err != nil {
+		meta.SetStatusCondition(&domain.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+	}
+	if err != nil || retry {
+		return releaseFailed(ctx, "domain", retry, err)
+	}
+
+	recordEvent(r.Recorder, domain, corev1.EventTypeNormal, "Orphaned", "Retain", "Domain %s was retained in MailU", domain.Spec.Name)
+	logr.Info("retaining domain in MailU")
+	return ctrl.Result{}, nil
+}
+
+// releaseFailed requeues the request after a retryable error, and returns any other error.
+func releaseFailed(ctx context.Context, kind string, retry bool, err error) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+	if retry {
+		if err != nil {
+			logr.Info(fmt.Errorf("failed to release %s, requeueing: %w", kind, err).Error())
+		}
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+	logr.Error(err, "failed to release "+kind)
+	return ctrl.Result{}, err
+}
+
+// appliedUserAliases returns the addresses of the aliases applied by the user, without those in conflict.
+func appliedUserAliases(user *operatorv1alpha1.User) []string {
+	addresses := []string{}
+	for _, state := range user.Status.Aliases {
+		if state.State != operatorv1alpha1.UserAliasStateConflict {
+			addresses = append(addresses, state.Address)
+		}
+	}
+	return addresses
+}
+
+// The release functions only update the comment of the object in MailU, and only if it is owned by the resource. They
+// return true, if a retryable error occurred.
+
+func releaseUser(ctx context.Context, api *mailu.Client, owner metav1.Object, email string) (bool, error) {
+	found, retry, err := getUser(ctx, api, email)
+	if err != nil || found == nil || !ownedBy(found.Comment, owner) {
+		return retry, err
+	}
+	comment := stripOwnerMarker(*found.Comment)
+	return updateUser(ctx, api, mailu.User{Email: email, Comment: &comment})
+}
+
+func releaseAlias(ctx context.Context, api *mailu.Client, owner metav1.Object, email string) (bool, error) {
+	found, retry, err := getAlias(ctx, api, email)
+	if err != nil || found == nil || !ownedBy(found.Comment, owner) {
+		return retry, err
+	}
+	comment := stripOwnerMarker(*found.Comment)
+	return updateAlias(ctx, api, mailu.Alias{Email: email, Comment: &comment})
+}
+
+// releaseAliases releases the aliases at the addresses, e.g. the aliases of a user or the role aliases of a domain.
+func releaseAliases(ctx context.Context, api *mailu.Client, owner metav1.Object, addresses []string) (bool, error) {
+	for _, address := range addresses {
+		if retry, err := releaseAlias(ctx, api, owner, address); err != nil || retry {
+			return retry, err
+		}
+	}
+	return false, nil
+}
+
+func (r *DomainReconciler) releaseDomain(ctx context.Context, domain *operatorv1alpha1.Domain) (bool, error) {
+	found, retry, err := r.getDomain(ctx, domain)
+	if err != nil || found == nil || !ownedBy(found.Comment, domain) {
+		return retry, err
+	}
+	comment := stripOwnerMarker(*found.Comment)
+	return r.updateDomain(ctx, mailu.Domain{Name: domain.Spec.Name, Comment: &comment})
+}
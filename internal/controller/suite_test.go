@@ -17,6 +17,7 @@ limitations under the License.
 package controller_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -26,6 +27,9 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/ghttp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	. "github.com/sickhub/mailu-operator/internal/controller"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -79,12 +84,78 @@ var _ = BeforeSuite(func() {
 
 	//+kubebuilder:scaffold:scheme
 
-	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
 	Expect(err).NotTo(HaveOccurred())
+	k8sClient = indexedClient{Client: c}
 	Expect(k8sClient).NotTo(BeNil())
 
 })
 
+// indexedClient evaluates the field selectors on the field indexes of the controllers in memory,
+// as they are only supported by the cache of the manager, which is not used in the tests.
+type indexedClient struct {
+	client.Client
+}
+
+func (c indexedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	selector := listOpts.FieldSelector
+	if selector == nil || selector.Empty() {
+		return c.Client.List(ctx, list, opts...)
+	}
+
+	listOpts.FieldSelector = nil
+	if err := c.Client.List(ctx, list, listOpts); err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	filtered := []apiruntime.Object{}
+	for _, item := range items {
+		set := fields.Set{}
+		// a field with multiple values matches, if any of its values matches
+		refs := []string{""}
+		switch o := item.(type) {
+		case *operatorv1alpha1.User:
+			set[IndexEmail] = o.Spec.Name + "@" + o.Spec.Domain
+			set[IndexDomain] = o.Spec.Domain
+			set[IndexUserClassName] = o.Spec.UserClassName
+		case *operatorv1alpha1.Alias:
+			set[IndexEmail] = o.Spec.Name + "@" + o.Spec.Domain
+			set[IndexDomain] = o.Spec.Domain
+			for i, ref := range o.Spec.DestinationRefs {
+				namespace := ref.Namespace
+				if namespace == "" {
+					namespace = o.Namespace
+				}
+				if i == 0 {
+					refs = nil
+				}
+				refs = append(refs, ref.Kind+"/"+namespace+"/"+ref.Name)
+			}
+		case *operatorv1alpha1.MailingList:
+			set[IndexEmail] = o.Spec.Name + "@" + o.Spec.Domain
+			set[IndexDomain] = o.Spec.Domain
+		case *operatorv1alpha1.Domain:
+			set[IndexDomainName] = o.Spec.Name
+		case *operatorv1alpha1.AutoReply:
+			set[IndexAutoReplyUser] = o.Namespace + "/" + o.Spec.UserName
+		}
+		for _, ref := range refs {
+			set[IndexDestinationRefs] = ref
+			if selector.Matches(set) {
+				filtered = append(filtered, item)
+				break
+			}
+		}
+	}
+	return meta.SetList(list, filtered)
+}
+
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
 	err := testEnv.Stop()
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+// userAliasAddresses returns the addresses of the aliases of the user. Aliases without a domain are in the domain
+// of the user.
+func userAliasAddresses(user *operatorv1alpha1.User) []string {
+	addresses := []string{}
+	for _, alias := range user.Spec.Aliases {
+		if !strings.Contains(alias, "@") {
+			alias = alias + "@" + user.Spec.Domain
+		}
+		if !slices.Contains(addresses, alias) {
+			addresses = append(addresses, alias)
+		}
+	}
+	return addresses
+}
+
+// reconcileAliases creates or updates the aliases of the user in MailU with the user as destination, and deletes the
+// aliases removed from the spec. Existing aliases not created by the user are left untouched.
+func (r *UserReconciler) reconcileAliases(ctx context.Context, user *operatorv1alpha1.User, result ctrl.Result) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if len(user.Spec.Aliases) == 0 && len(user.Status.Aliases) == 0 {
+		return result, nil
+	}
+	email := user.Spec.Name + "@" + user.Spec.Domain
+	desired := userAliasAddresses(user)
+	if observeOnly(user, r.ObserveOnly) {
+		return r.planAliases(ctx, user, desired, email, result), nil
+	}
+
+	retryAny := false
+	states := []operatorv1alpha1.UserAliasStatus{}
+	for _, address := range desired {
+		state, retry := r.applyAlias(ctx, user, address, email)
+		retryAny = retryAny || retry
+		states = append(states, state)
+	}
+
+	// aliases applied before, but removed from the spec, are deleted
+	for _, state := range user.Status.Aliases {
+		// an alias in conflict is not managed by the user, an alias in error may have been applied before
+		if state.State == operatorv1alpha1.UserAliasStateConflict || slices.Contains(desired, state.Address) {
+			continue
+		}
+		retry, err := deleteAlias(ctx, r.ApiClient, state.Address)
+		if err != nil || retry {
+			// keep the alias in the status to delete it again with the next reconciliation
+			state.Message = deleteAliasMessage(err)
+			states = append(states, state)
+			retryAny = true
+			continue
+		}
+		logr.Info("deleted alias of user", "alias", state.Address)
+	}
+
+	user.Status.Aliases = states
+	if retryAny {
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+	return result, nil
+}
+
+// planAliases reports the aliases of the user that would be created, updated or deleted in MailU.
+func (r *UserReconciler) planAliases(ctx context.Context, user *operatorv1alpha1.User, desired []string, email string, result ctrl.Result) ctrl.Result {
+	create, update, remove := []string{}, []string{}, []string{}
+	for _, address := range desired {
+		action, retry, err := planOwnedAlias(ctx, r.ApiClient, user, address, []string{email}, false)
+		if err != nil || retry {
+			log.FromContext(ctx).Info("failed to get alias of user, requeueing", "alias", address)
+			return ctrl.Result{RequeueAfter: 5 * time.Second}
+		}
+		switch action {
+		case "Create":
+			create = append(create, address)
+		case "Update":
+			update = append(update, address)
+		}
+	}
+	for _, state := range user.Status.Aliases {
+		if state.State != operatorv1alpha1.UserAliasStateConflict && !slices.Contains(desired, state.Address) {
+			remove = append(remove, state.Address)
+		}
+	}
+
+	if plan := aliasPlan(create, update, remove); plan != "" {
+		reportPlan(r.Recorder, user, &user.Status.Conditions, "Update", "Would apply aliases of user "+email+" in MailU: "+plan)
+	}
+	return result
+}
+
+// applyAlias creates or updates a single alias of the user and returns its state, and if a retryable error occurred.
+func (r *UserReconciler) applyAlias(ctx context.Context, user *operatorv1alpha1.User, address, email string) (operatorv1alpha1.UserAliasStatus, bool) {
+	destination := []string{email}
+	state, message, retry := applyOwnedAlias(ctx, r.ApiClient, user, address, destination, false)
+	if state == operatorv1alpha1.UserAliasStateApplied {
+		log.FromContext(ctx).Info("applied alias of user", "alias", address)
+	}
+	return operatorv1alpha1.UserAliasStatus{Address: address, State: state, Message: message}, retry
+}
+
+// deleteAliases deletes all aliases applied by the user in MailU. It requeues the request, until all are deleted.
+func (r *UserReconciler) deleteAliases(ctx context.Context, user *operatorv1alpha1.User) ctrl.Result {
+	logr := log.FromContext(ctx)
+
+	if len(user.Status.Aliases) == 0 || observeOnly(user, r.ObserveOnly) {
+		return ctrl.Result{}
+	}
+
+	states := []operatorv1alpha1.UserAliasStatus{}
+	for _, state := range user.Status.Aliases {
+		if state.State == operatorv1alpha1.UserAliasStateConflict {
+			continue
+		}
+		retry, err := deleteAlias(ctx, r.ApiClient, state.Address)
+		if err != nil || retry {
+			state.Message = deleteAliasMessage(err)
+			states = append(states, state)
+			continue
+		}
+		logr.Info("deleted alias of user", "alias", state.Address)
+	}
+
+	user.Status.Aliases = states
+	if len(states) > 0 {
+		logr.Info("failed to delete aliases of user, requeueing", "count", len(states))
+		return ctrl.Result{RequeueAfter: 5 * time.Second}
+	}
+	return ctrl.Result{}
+}
@@ -9,20 +9,27 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	openapitypes "github.com/oapi-codegen/runtime/types"
 	"github.com/sethvargo/go-password/password"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
@@ -36,16 +43,25 @@ const (
 // UserReconciler reconciles a User object
 type UserReconciler struct {
 	client.Client
-	Scheme    *runtime.Scheme
-	ApiURL    string
-	ApiToken  string
-	ApiClient *mailu.Client
+	Scheme         *runtime.Scheme
+	Recorder       events.EventRecorder
+	ApiURL         string
+	ApiToken       string
+	ApiClient      *mailu.Client
+	ResyncInterval time.Duration
+	DriftPolicy    string
+	ObserveOnly    bool
+	DeletionPolicy operatorv1alpha1.DeletionPolicy
 }
 
 //+kubebuilder:rbac:groups=operator.mailu.io,resources=users,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=operator.mailu.io,resources=users/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=operator.mailu.io,resources=users/finalizers,verbs=update
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=userclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=operator.mailu.io,resources=forwardingpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -71,6 +87,31 @@ func (r *UserReconciler) Reconcile(ctx context.Context, user *operatorv1alpha1.U
 		controllerutil.AddFinalizer(user, FinalizerName)
 	}
 
+	// skip all calls to MailU, including the deletion, until the annotation is removed
+	if paused(user) {
+		meta.SetStatusCondition(&user.Status.Conditions, getPausedCondition())
+		logr.Info("reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&user.Status.Conditions, ConditionTypePaused)
+
+	// only the oldest resource targeting an object in MailU manages it, the others must not call MailU
+	winner, err := findConflictWinner(ctx, r.Client, "User", user)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if winner != "" {
+		msg := fmt.Sprintf("%s is already managed by %s", user.Spec.Name+"@"+user.Spec.Domain, winner)
+		meta.SetStatusCondition(&user.Status.Conditions, getConflictCondition(msg))
+		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Conflict", msg))
+		logr.Info("conflicting resource, skipping reconciliation", "winner", winner)
+		if userOriginal.DeletionTimestamp != nil {
+			controllerutil.RemoveFinalizer(user, FinalizerName)
+		}
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&user.Status.Conditions, ConditionTypeConflict)
+
 	result, err := r.reconcile(ctx, user)
 	if err != nil {
 		return result, err
@@ -80,6 +121,13 @@ func (r *UserReconciler) Reconcile(ctx context.Context, user *operatorv1alpha1.U
 		controllerutil.RemoveFinalizer(user, FinalizerName)
 	}
 
+	// the user is enabled, disabled or expires at the next transition of its lifecycle
+	if userOriginal.DeletionTimestamp == nil {
+		now := time.Now()
+		_, next := userPhase(user, now)
+		result = requeueAt(result, next, now)
+	}
+
 	return result, nil
 }
 
@@ -97,7 +145,30 @@ func (r *UserReconciler) reconcile(ctx context.Context, user *operatorv1alpha1.U
 		r.ApiClient = api
 	}
 
-	foundUser, retry, err := r.getUser(ctx, user)
+	if !observeOnly(user, r.ObserveOnly) {
+		meta.RemoveStatusCondition(&user.Status.Conditions, ConditionTypeObserveOnly)
+	}
+
+	if user.DeletionTimestamp != nil && deletionPolicy(user.Spec.DeletionPolicy, r.DeletionPolicy) == operatorv1alpha1.DeletionPolicyRetain {
+		return r.retain(ctx, user)
+	}
+
+	email := user.Spec.Name + "@" + user.Spec.Domain
+	if renamed(user.Status.AppliedAddress, email) && user.DeletionTimestamp == nil &&
+		user.Spec.RenamePolicy != operatorv1alpha1.RenamePolicyRecreate {
+		msg := fmt.Sprintf("User cannot be renamed from %s to %s, revert the change or set renamePolicy Recreate", user.Status.AppliedAddress, email)
+		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "RenameRejected", msg))
+		logr.Info("rejecting rename of user", "from", user.Status.AppliedAddress, "to", email)
+		return ctrl.Result{}, nil
+	}
+
+	// a deleted user is removed at the address it was applied with, which may differ from the spec
+	address := email
+	if user.DeletionTimestamp != nil && user.Status.AppliedAddress != "" {
+		address = user.Status.AppliedAddress
+	}
+
+	foundUser, retry, err := getUser(ctx, r.ApiClient, address)
 	if err != nil {
 		if retry {
 			logr.Info(fmt.Errorf("failed to get user, requeueing: %w", err).Error())
@@ -110,24 +181,120 @@ func (r *UserReconciler) reconcile(ctx context.Context, user *operatorv1alpha1.U
 	}
 
 	if user.DeletionTimestamp != nil {
-		if foundUser == nil {
-			// no need to delete it, if it does not exist
+		if result := r.deleteAliases(ctx, user); result.RequeueAfter > 0 {
+			return result, nil
+		}
+		if foundUser == nil || (user.Status.ObservedGeneration == 0 && !ownedBy(foundUser.Comment, user)) {
+			// no need to delete it, if it does not exist or was never applied by this resource
 			return ctrl.Result{}, nil
 		}
-		return r.delete(ctx, user)
+		return r.delete(ctx, user, address)
 	}
 
+	phase, _ := userPhase(user, time.Now())
+	if phase == operatorv1alpha1.UserPhaseExpired && expiryAction(user) == operatorv1alpha1.UserExpiryActionDelete {
+		return r.expire(ctx, user, foundUser, address)
+	}
+
+	// settings not set on the user are taken from its class, then from its domain
+	class, err := userClassSettings(ctx, r.Client, user)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			msg := fmt.Sprintf("UserClass %s not found", user.Spec.UserClassName)
+			meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "UserClassNotFound", msg))
+			logr.Info("waiting for user class", "class", user.Spec.UserClassName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	defaults, err := DomainUserDefaults(ctx, r.Client, user.Spec.Domain)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	denied, err := forwardingDenied(ctx, r.Client, user)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	target := userTarget{
+		settings:         mergeUserSettings(&user.Spec.UserSettings, class, defaults),
+		phase:            phase,
+		forwardingDenied: denied,
+	}
+
+	var result ctrl.Result
 	if foundUser == nil {
-		return r.create(ctx, user)
+		// the user was applied before at this address and did not expire, so it has been deleted in MailU
+		if user.Status.ObservedGeneration > 0 && !renamed(user.Status.AppliedAddress, email) && user.Status.Phase != operatorv1alpha1.UserPhaseExpired {
+			meta.SetStatusCondition(&user.Status.Conditions, getDriftedCondition("User was deleted in MailU"))
+			if driftPolicy(user, r.DriftPolicy) == DriftPolicyReport {
+				recordEvent(r.Recorder, user, corev1.EventTypeWarning, "Drifted", "Report", "User was deleted in MailU")
+				logr.Info("user was deleted in MailU, not recreating it")
+				return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
+			}
+			recordEvent(r.Recorder, user, corev1.EventTypeWarning, "Drifted", "Correct", "User was deleted in MailU, recreating it")
+		}
+		if result, wait := waitForDomain(ctx, r.Client, r.ApiClient, user, &user.Status.Conditions, UserConditionTypeReady, resyncInterval(user, r.ResyncInterval)); wait {
+			return result, nil
+		}
+		result, err = r.create(ctx, user, target)
+	} else {
+		result, err = r.update(ctx, user, foundUser, target)
+	}
+	if err != nil || user.Status.ObservedGeneration != user.Generation {
+		return result, err
+	}
+
+	result, err = r.applied(ctx, user, result)
+	if err != nil {
+		return result, err
 	}
 
-	return r.update(ctx, user, foundUser)
+	return r.reconcileAliases(ctx, user, result)
 }
 
-func (r *UserReconciler) create(ctx context.Context, user *operatorv1alpha1.User) (ctrl.Result, error) {
+// applied records the address of the applied user. After a rename, the user at the previous address is deleted.
+func (r *UserReconciler) applied(ctx context.Context, user *operatorv1alpha1.User, result ctrl.Result) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
-	retry, err := r.createUser(ctx, user)
+	email := user.Spec.Name + "@" + user.Spec.Domain
+	if renamed(user.Status.AppliedAddress, email) {
+		if observeOnly(user, r.ObserveOnly) {
+			reportPlan(r.Recorder, user, &user.Status.Conditions, "Delete", "Would delete renamed user "+user.Status.AppliedAddress+" in MailU")
+			logr.Info("observe-only, not deleting renamed user")
+			return result, nil
+		}
+
+		retry, err := r.deleteUser(ctx, user.Status.AppliedAddress)
+		if err != nil {
+			meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+			if retry {
+				logr.Info(fmt.Errorf("failed to delete renamed user, requeueing: %w", err).Error())
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			logr.Error(err, "failed to delete renamed user")
+			return ctrl.Result{}, err
+		}
+		if retry {
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		recordEvent(r.Recorder, user, corev1.EventTypeNormal, "Renamed", "Recreate", "User %s was recreated as %s", user.Status.AppliedAddress, email)
+		logr.Info("deleted renamed user", "from", user.Status.AppliedAddress, "to", email)
+	}
+
+	user.Status.AppliedAddress = email
+	return result, nil
+}
+
+func (r *UserReconciler) create(ctx context.Context, user *operatorv1alpha1.User, target userTarget) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if observeOnly(user, r.ObserveOnly) {
+		reportPlan(r.Recorder, user, &user.Status.Conditions, "Create", "Would create user "+user.Spec.Name+"@"+user.Spec.Domain+" in MailU")
+		logr.Info("observe-only, not creating user")
+		return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
+	}
+
+	retry, err := r.createUser(ctx, user, target)
 	if err != nil {
 		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
 		if retry {
@@ -142,16 +309,19 @@ func (r *UserReconciler) create(ctx context.Context, user *operatorv1alpha1.User
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
+	meta.RemoveStatusCondition(&user.Status.Conditions, ConditionTypeDrifted)
 	meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionTrue, "Created", "User created in MailU"))
+	user.Status.ObservedGeneration = user.Generation
+	r.setApplied(user, target)
 	logr.Info("created user")
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
 }
 
-func (r *UserReconciler) update(ctx context.Context, user *operatorv1alpha1.User, apiUser *mailu.User) (ctrl.Result, error) {
+func (r *UserReconciler) update(ctx context.Context, user *operatorv1alpha1.User, apiUser *mailu.User, target userTarget) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
-	newUser, err := r.userFromSpec(user.Spec)
+	newUser, err := r.userFromSpec(user, target)
 	if err != nil {
 		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
 		logr.Error(err, "failed to get user from spec")
@@ -163,16 +333,76 @@ func (r *UserReconciler) update(ctx context.Context, user *operatorv1alpha1.User
 	apiUser.Password = nil
 	apiUser.QuotaBytesUsed = nil
 
+	// keep the values of ignored fields as they are in MailU, the reply fields are managed by an AutoReply if any
+	patterns := user.Spec.IgnoreFields
+	managed, err := managedByAutoReply(ctx, r.Client, user)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if managed {
+		patterns = append(slices.Clone(patterns), "reply*")
+	}
+	newUser, err = ignoreFields(newUser, *apiUser, userFields, patterns)
+	if err != nil {
+		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
+		logr.Error(err, "failed to apply ignored fields")
+		return ctrl.Result{}, err
+	}
+	// ignored fields do not bypass the phase of the user or the forwarding policies
+	restrictUser(&newUser, target)
+
+	// the user exists in MailU, but was never applied by this resource (at this address)
+	if (user.Status.ObservedGeneration == 0 || renamed(user.Status.AppliedAddress, newUser.Email)) && !ownedBy(apiUser.Comment, user) {
+		diff := withoutOwnerMarkerDiff(diffFields(newUser, *apiUser, userFields), newUser.Comment, apiUser.Comment)
+		reason, message := checkAdoption(user.Spec.AdoptionPolicy, "User", diff)
+		if reason != "" {
+			meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, reason, message))
+			logr.Info("not adopting existing user: " + message)
+			return ctrl.Result{}, nil
+		}
+		meta.SetStatusCondition(&user.Status.Conditions, getAdoptedCondition("User adopted from MailU"))
+		recordEvent(r.Recorder, user, corev1.EventTypeNormal, "Adopted", "Adopt", "Adopted existing user %s from MailU", user.Spec.Name+"@"+user.Spec.Domain)
+		logr.Info("adopting existing user")
+	}
+
 	jsonNew, _ := json.Marshal(newUser) //nolint:errcheck
 	jsonOld, _ := json.Marshal(apiUser) //nolint:errcheck
 
 	if reflect.DeepEqual(jsonNew, jsonOld) {
+		meta.RemoveStatusCondition(&user.Status.Conditions, ConditionTypeDrifted)
 		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionTrue, "Updated", "User updated in MailU"))
+		user.Status.ObservedGeneration = user.Generation
+		r.setApplied(user, target)
+		if observeOnly(user, r.ObserveOnly) {
+			reportPlan(r.Recorder, user, &user.Status.Conditions, "None", "User is up to date in MailU")
+		}
 		logr.Info("user is up to date, no change needed")
-		return ctrl.Result{}, nil
+		return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
+	}
+
+	// neither the spec, the defaults of the domain, the phase nor the forwarding policies changed since they were last
+	// applied, so the user has been changed in MailU (a missing ownership marker is no drift, it is added with the next
+	// update)
+	drift := withoutOwnerMarkerDiff(diffFields(newUser, *apiUser, userFields), newUser.Comment, apiUser.Comment)
+	if user.Status.ObservedGeneration == user.Generation && target.applied(user) && len(drift) > 0 {
+		fields := strings.Join(drift, ", ")
+		meta.SetStatusCondition(&user.Status.Conditions, getDriftedCondition("User differs in MailU: "+fields))
+		if driftPolicy(user, r.DriftPolicy) == DriftPolicyReport {
+			recordEvent(r.Recorder, user, corev1.EventTypeWarning, "Drifted", "Report", "User differs in MailU: %s", fields)
+			logr.Info("user differs in MailU, not correcting it", "fields", fields)
+			return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
+		}
+		recordEvent(r.Recorder, user, corev1.EventTypeWarning, "Drifted", "Correct", "User differs in MailU, correcting: %s", fields)
 	}
 
-	retry, err := r.updateUser(ctx, newUser)
+	if observeOnly(user, r.ObserveOnly) {
+		fields := strings.Join(diffFields(newUser, *apiUser, userFields), ", ")
+		reportPlan(r.Recorder, user, &user.Status.Conditions, "Update", "Would update user "+user.Spec.Name+"@"+user.Spec.Domain+" in MailU: "+fields)
+		logr.Info("observe-only, not updating user", "fields", fields)
+		return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
+	}
+
+	retry, err := updateUser(ctx, r.ApiClient, newUser)
 	if err != nil {
 		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
 		if retry {
@@ -187,16 +417,57 @@ func (r *UserReconciler) update(ctx context.Context, user *operatorv1alpha1.User
 		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
+	meta.RemoveStatusCondition(&user.Status.Conditions, ConditionTypeDrifted)
 	meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionTrue, "Updated", "User updated in MailU"))
+	user.Status.ObservedGeneration = user.Generation
+	r.setApplied(user, target)
 	logr.Info("updated user")
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
+}
+
+// userTarget is the state a user is applied with in MailU, derived from its spec, class, domain, lifecycle and the
+// forwarding policies.
+type userTarget struct {
+	settings         operatorv1alpha1.UserSettings
+	phase            operatorv1alpha1.UserPhase
+	forwardingDenied string
 }
 
-func (r *UserReconciler) delete(ctx context.Context, user *operatorv1alpha1.User) (ctrl.Result, error) {
+// applied returns true if the user was last applied with this target.
+func (t userTarget) applied(user *operatorv1alpha1.User) bool {
+	denied := ""
+	if c := meta.FindStatusCondition(user.Status.Conditions, ConditionTypeForwardingDenied); c != nil {
+		denied = c.Message
+	}
+	return equality.Semantic.DeepEqual(user.Status.Settings, &t.settings) && user.Status.Phase == t.phase && denied == t.forwardingDenied
+}
+
+// setApplied records the target the user was applied with in its status.
+func (r *UserReconciler) setApplied(user *operatorv1alpha1.User, target userTarget) {
+	settings := target.settings
+	user.Status.Settings = &settings
+	r.setPhase(user, target.phase)
+	if target.forwardingDenied == "" {
+		meta.RemoveStatusCondition(&user.Status.Conditions, ConditionTypeForwardingDenied)
+		return
+	}
+	if !meta.IsStatusConditionTrue(user.Status.Conditions, ConditionTypeForwardingDenied) {
+		recordEvent(r.Recorder, user, corev1.EventTypeWarning, "ForwardingDenied", "Disable", "%s, forwarding is disabled", target.forwardingDenied)
+	}
+	meta.SetStatusCondition(&user.Status.Conditions, getForwardingDeniedCondition(target.forwardingDenied))
+}
+
+func (r *UserReconciler) delete(ctx context.Context, user *operatorv1alpha1.User, email string) (ctrl.Result, error) {
 	logr := log.FromContext(ctx)
 
-	retry, err := r.deleteUser(ctx, user)
+	if observeOnly(user, r.ObserveOnly) {
+		reportPlan(r.Recorder, user, &user.Status.Conditions, "Delete", "Would delete user "+email+" in MailU")
+		logr.Info("observe-only, not deleting user")
+		return ctrl.Result{}, nil
+	}
+
+	retry, err := r.deleteUser(ctx, email)
 	if err != nil {
 		meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Error", err.Error()))
 		if retry {
@@ -216,8 +487,8 @@ func (r *UserReconciler) delete(ctx context.Context, user *operatorv1alpha1.User
 	return ctrl.Result{}, nil
 }
 
-func (r *UserReconciler) getUser(ctx context.Context, user *operatorv1alpha1.User) (*mailu.User, bool, error) {
-	found, err := r.ApiClient.FindUser(ctx, user.Spec.Name+"@"+user.Spec.Domain)
+func getUser(ctx context.Context, api *mailu.Client, email string) (*mailu.User, bool, error) {
+	found, err := api.FindUser(ctx, email)
 	if err != nil {
 		return nil, false, err
 	}
@@ -251,7 +522,7 @@ func (r *UserReconciler) getUser(ctx context.Context, user *operatorv1alpha1.Use
 	return nil, false, errors.New("unknown status: " + strconv.Itoa(found.StatusCode))
 }
 
-func (r *UserReconciler) createUser(ctx context.Context, user *operatorv1alpha1.User) (bool, error) {
+func (r *UserReconciler) createUser(ctx context.Context, user *operatorv1alpha1.User, target userTarget) (bool, error) {
 	logr := log.FromContext(ctx, "user", user.Name)
 	email := user.Spec.Name + "@" + user.Spec.Domain
 
@@ -266,7 +537,7 @@ func (r *UserReconciler) createUser(ctx context.Context, user *operatorv1alpha1.
 		}
 	}
 
-	newUser, err := r.userFromSpec(user.Spec)
+	newUser, err := r.userFromSpec(user, target)
 	if err != nil {
 		return false, err
 	}
@@ -302,8 +573,8 @@ func (r *UserReconciler) createUser(ctx context.Context, user *operatorv1alpha1.
 	return false, errors.New("unknown status: " + strconv.Itoa(res.StatusCode))
 }
 
-func (r *UserReconciler) updateUser(ctx context.Context, newUser mailu.User) (bool, error) {
-	res, err := r.ApiClient.UpdateUser(ctx, newUser.Email, newUser)
+func updateUser(ctx context.Context, api *mailu.Client, newUser mailu.User) (bool, error) {
+	res, err := api.UpdateUser(ctx, newUser.Email, newUser)
 	if err != nil {
 		return false, err
 	}
@@ -332,8 +603,8 @@ func (r *UserReconciler) updateUser(ctx context.Context, newUser mailu.User) (bo
 	return false, errors.New("unknown status: " + strconv.Itoa(res.StatusCode))
 }
 
-func (r *UserReconciler) deleteUser(ctx context.Context, user *operatorv1alpha1.User) (bool, error) {
-	res, err := r.ApiClient.DeleteUser(ctx, user.Spec.Name+"@"+user.Spec.Domain)
+func (r *UserReconciler) deleteUser(ctx context.Context, email string) (bool, error) {
+	res, err := r.ApiClient.DeleteUser(ctx, email)
 	if err != nil {
 		return false, err
 	}
@@ -362,28 +633,33 @@ func (r *UserReconciler) deleteUser(ctx context.Context, user *operatorv1alpha1.
 	return false, errors.New("unknown status: " + strconv.Itoa(res.StatusCode))
 }
 
-func (r *UserReconciler) userFromSpec(spec operatorv1alpha1.UserSpec) (mailu.User, error) {
+// userFromSpec returns the user in MailU with the effective settings of the user, enabled only in an active phase and
+// forwarding only if no ForwardingPolicy denies it.
+func (r *UserReconciler) userFromSpec(user *operatorv1alpha1.User, target userTarget) (mailu.User, error) {
+	spec := user.Spec
+	settings := target.settings
+	comment := withOwnerMarker(spec.Comment, user)
 	u := mailu.User{
 		Email:              spec.Name + "@" + spec.Domain,
-		AllowSpoofing:      &spec.AllowSpoofing,
+		AllowSpoofing:      settings.AllowSpoofing,
 		ChangePwNextLogin:  &spec.ChangePassword,
-		Comment:            &spec.Comment,
+		Comment:            &comment,
 		DisplayedName:      &spec.DisplayedName,
-		EnableImap:         &spec.EnableIMAP,
-		EnablePop:          &spec.EnablePOP,
+		EnableImap:         settings.EnableIMAP,
+		EnablePop:          settings.EnablePOP,
 		Enabled:            &spec.Enabled,
 		ForwardDestination: &spec.ForwardDestination,
 		ForwardEnabled:     &spec.ForwardEnabled,
 		ForwardKeep:        &spec.ForwardKeep,
 		GlobalAdmin:        &spec.GlobalAdmin,
-		QuotaBytes:         &spec.QuotaBytes,
+		QuotaBytes:         settings.QuotaBytes,
 		RawPassword:        &spec.RawPassword,
 		ReplyBody:          &spec.ReplyBody,
 		ReplyEnabled:       &spec.ReplyEnabled,
 		ReplySubject:       &spec.ReplySubject,
-		SpamEnabled:        &spec.SpamEnabled,
-		SpamMarkAsRead:     &spec.SpamMarkAsRead,
-		SpamThreshold:      &spec.SpamThreshold,
+		SpamEnabled:        settings.SpamEnabled,
+		SpamMarkAsRead:     settings.SpamMarkAsRead,
+		SpamThreshold:      settings.SpamThreshold,
 	}
 
 	// convert Dates if set
@@ -404,9 +680,22 @@ func (r *UserReconciler) userFromSpec(spec operatorv1alpha1.UserSpec) (mailu.Use
 		u.ReplyEndDate = d
 	}
 
+	restrictUser(&u, target)
 	return u, nil
 }
 
+// restrictUser disables the user outside of its active phase and its forwarding if it is denied by a policy.
+func restrictUser(u *mailu.User, target userTarget) {
+	if !phaseEnabled(target.phase) {
+		disabled := false
+		u.Enabled = &disabled
+	}
+	if target.forwardingDenied != "" {
+		disabled := false
+		u.ForwardEnabled = &disabled
+	}
+}
+
 func (r *UserReconciler) getRawUserPassword(ctx context.Context, user *operatorv1alpha1.User) (string, error) {
 	var err error
 	pass := ""
@@ -463,5 +752,12 @@ func getUserReadyCondition(status metav1.ConditionStatus, reason, message string
 func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&operatorv1alpha1.User{}).
+		Watches(&operatorv1alpha1.User{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.UserList{})).
+		Watches(&operatorv1alpha1.Alias{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.UserList{})).
+		Watches(&operatorv1alpha1.MailingList{}, enqueueConflicting(mgr.GetClient(), &operatorv1alpha1.UserList{})).
+		Watches(&operatorv1alpha1.Domain{}, enqueueWaitingForDomain(mgr.GetClient(), &operatorv1alpha1.UserList{})).
+		Watches(&operatorv1alpha1.Domain{}, enqueueDefaultedUsers(mgr.GetClient()), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&operatorv1alpha1.UserClass{}, enqueueClassifiedUsers(mgr.GetClient()), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&operatorv1alpha1.ForwardingPolicy{}, enqueueForwardingUsers(mgr.GetClient()), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(reconcile.AsReconciler(r.Client, r))
 }
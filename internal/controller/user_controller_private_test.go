@@ -3,16 +3,24 @@ package controller
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
 	"github.com/sickhub/mailu-operator/pkg/mailu"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestUserReconciler_getRawUserPassword(t *testing.T) {
@@ -172,3 +180,214 @@ func TestUserReconciler_getRawUserPassword(t *testing.T) {
 		})
 	}
 }
+
+func Test_userAliasAddresses(t *testing.T) {
+	user := &operatorv1alpha1.User{
+		Spec: operatorv1alpha1.UserSpec{
+			Name:    "john.doe",
+			Domain:  "example.com",
+			Aliases: []string{"john", "j.doe", "john@example.org", "john"},
+		},
+	}
+	want := []string{"john@example.com", "j.doe@example.com", "john@example.org"}
+	if got := userAliasAddresses(user); !reflect.DeepEqual(got, want) {
+		t.Errorf("userAliasAddresses() = %v, want %v", got, want)
+	}
+}
+
+func Test_mergeUserSettings(t *testing.T) {
+	enabled, disabled, quota, domainQuota := true, false, int64(1000), int64(2000)
+	user := &operatorv1alpha1.UserSettings{EnableIMAP: &disabled, QuotaBytes: &quota}
+	defaults := &operatorv1alpha1.UserSettings{EnableIMAP: &enabled, SpamEnabled: &enabled, QuotaBytes: &domainQuota}
+
+	got := mergeUserSettings(user, defaults)
+	if *got.EnableIMAP || *got.QuotaBytes != 1000 {
+		t.Errorf("mergeUserSettings() did not keep the settings of the user: %+v", got)
+	}
+	if !*got.SpamEnabled {
+		t.Errorf("mergeUserSettings() did not apply the defaults of the domain: %+v", got)
+	}
+	if got.AllowSpoofing == nil || *got.AllowSpoofing || *got.SpamThreshold != 0 {
+		t.Errorf("mergeUserSettings() did not apply the defaults of the operator: %+v", got)
+	}
+
+	if got := mergeUserSettings(&operatorv1alpha1.UserSettings{}, nil); *got.QuotaBytes != -1 || *got.EnablePOP {
+		t.Errorf("mergeUserSettings() without defaults = %+v", got)
+	}
+
+	class := &operatorv1alpha1.UserSettings{EnableIMAP: &enabled, SpamEnabled: &disabled, EnablePOP: &enabled}
+	got = mergeUserSettings(user, class, defaults)
+	if *got.EnableIMAP || *got.QuotaBytes != 1000 {
+		t.Errorf("mergeUserSettings() did not keep the settings of the user over the class: %+v", got)
+	}
+	if *got.SpamEnabled || !*got.EnablePOP {
+		t.Errorf("mergeUserSettings() did not apply the class over the defaults of the domain: %+v", got)
+	}
+}
+
+func Test_mapClassifiedUsers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	staff := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "staff", Namespace: "default"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "staff", Domain: "example.com", UserClassName: "staff"},
+	}
+	other := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"},
+		Spec:       operatorv1alpha1.UserSpec{Name: "other", Domain: "example.com"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(staff, other).
+		WithIndex(&operatorv1alpha1.User{}, IndexUserClassName, func(obj client.Object) []string {
+			return []string{obj.(*operatorv1alpha1.User).Spec.UserClassName}
+		}).Build()
+
+	class := &operatorv1alpha1.UserClass{ObjectMeta: metav1.ObjectMeta{Name: "staff"}}
+	got := mapClassifiedUsers(c)(context.Background(), class)
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "staff", Namespace: "default"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mapClassifiedUsers() = %v, want %v", got, want)
+	}
+}
+
+func Test_domainUserDefaults(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled := true
+	managing := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default", CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))},
+		Spec:       operatorv1alpha1.DomainSpec{Name: "example.com", UserDefaults: &operatorv1alpha1.UserSettings{EnableIMAP: &enabled}},
+	}
+	// a transient error does not hand the domain over to a conflicting resource
+	meta.SetStatusCondition(&managing.Status.Conditions, getDomainReadyCondition(metav1.ConditionFalse, "Error", "MailU is not available"))
+	conflicting := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "conflicting", Namespace: "other", CreationTimestamp: metav1.Now()},
+		Spec:       operatorv1alpha1.DomainSpec{Name: "example.com", UserDefaults: &operatorv1alpha1.UserSettings{EnablePOP: &enabled}},
+	}
+	meta.SetStatusCondition(&conflicting.Status.Conditions, getConflictCondition("example.com is already managed by Domain default/example"))
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(conflicting, managing).
+		WithIndex(&operatorv1alpha1.Domain{}, IndexDomainName, func(obj client.Object) []string {
+			return []string{obj.(*operatorv1alpha1.Domain).Spec.Name}
+		}).Build()
+
+	got, err := DomainUserDefaults(context.Background(), c, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, managing.Spec.UserDefaults) {
+		t.Errorf("DomainUserDefaults() = %+v, want %+v", got, managing.Spec.UserDefaults)
+	}
+
+	got, err = DomainUserDefaults(context.Background(), c, "example.org")
+	if err != nil || got != nil {
+		t.Errorf("DomainUserDefaults() of unmanaged domain = %+v, %v", got, err)
+	}
+}
+
+func Test_userPhase(t *testing.T) {
+	at := func(day int) *metav1.Time {
+		t := metav1.NewTime(time.Date(2024, 8, day, 0, 0, 0, 0, time.UTC))
+		return &t
+	}
+	user := &operatorv1alpha1.User{Spec: operatorv1alpha1.UserSpec{ActiveFrom: at(1), ActiveUntil: at(20)}}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantPhase operatorv1alpha1.UserPhase
+		wantNext  time.Time
+	}{
+		{name: "before activeFrom", now: at(1).Add(-time.Hour), wantPhase: operatorv1alpha1.UserPhasePending, wantNext: at(1).Time},
+		{name: "at activeFrom", now: at(1).Time, wantPhase: operatorv1alpha1.UserPhaseActive, wantNext: at(13).Time},
+		{name: "within a week of activeUntil", now: at(13).Time, wantPhase: operatorv1alpha1.UserPhaseExpiring, wantNext: at(20).Time},
+		{name: "at activeUntil", now: at(20).Time, wantPhase: operatorv1alpha1.UserPhaseExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phase, next := userPhase(user, tt.now)
+			if phase != tt.wantPhase || !next.Equal(tt.wantNext) {
+				t.Errorf("userPhase() = %s, %v, want %s, %v", phase, next, tt.wantPhase, tt.wantNext)
+			}
+		})
+	}
+
+	if phase, next := userPhase(&operatorv1alpha1.User{}, time.Now()); phase != operatorv1alpha1.UserPhaseActive || !next.IsZero() {
+		t.Errorf("userPhase() without lifecycle = %s, %v", phase, next)
+	}
+}
+
+func TestUserReconciler_userFromSpec(t *testing.T) {
+	r := &UserReconciler{}
+	user := &operatorv1alpha1.User{Spec: operatorv1alpha1.UserSpec{Name: "john.doe", Domain: "example.com", Enabled: true}}
+	target := userTarget{settings: mergeUserSettings(&user.Spec.UserSettings)}
+
+	for phase, want := range map[operatorv1alpha1.UserPhase]bool{
+		operatorv1alpha1.UserPhasePending:  false,
+		operatorv1alpha1.UserPhaseActive:   true,
+		operatorv1alpha1.UserPhaseExpiring: true,
+		operatorv1alpha1.UserPhaseExpired:  false,
+	} {
+		target.phase = phase
+		got, err := r.userFromSpec(user, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got.Enabled != want {
+			t.Errorf("userFromSpec() in phase %s enabled = %v, want %v", phase, *got.Enabled, want)
+		}
+	}
+}
+
+func TestUserReconciler_update_ignoreFields(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithIndex(&operatorv1alpha1.AutoReply{}, IndexAutoReplyUser, func(obj client.Object) []string {
+			o := obj.(*operatorv1alpha1.AutoReply)
+			return []string{o.Namespace + "/" + o.Spec.UserName}
+		}).Build()
+
+	var patched mailu.User
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&patched); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	api, err := mailu.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &UserReconciler{Client: c, ApiClient: api}
+
+	user := &operatorv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: "default", UID: "1234", Generation: 1},
+		Spec: operatorv1alpha1.UserSpec{Name: "john.doe", Domain: "example.com", Enabled: true, ForwardEnabled: true,
+			ForwardDestination: []string{"john@example.org"}, IgnoreFields: []string{"enabled", "forward*"}},
+		Status: operatorv1alpha1.UserStatus{ObservedGeneration: 1},
+	}
+	target := userTarget{settings: mergeUserSettings(&user.Spec.UserSettings), phase: operatorv1alpha1.UserPhaseExpired,
+		forwardingDenied: "forwarding to example.org is denied by ForwardingPolicy external"}
+
+	enabled, comment := true, withOwnerMarker("", user)
+	apiUser := &mailu.User{Email: "john.doe@example.com", Comment: &comment, Enabled: &enabled, ForwardEnabled: &enabled}
+	if _, err := r.update(context.Background(), user, apiUser, target); err != nil {
+		t.Fatal(err)
+	}
+	if patched.Enabled == nil || *patched.Enabled {
+		t.Errorf("update() enabled = %v, want false for an expired user", patched.Enabled)
+	}
+	if patched.ForwardEnabled == nil || *patched.ForwardEnabled {
+		t.Errorf("update() forwardEnabled = %v, want false if forwarding is denied", patched.ForwardEnabled)
+	}
+}
@@ -3,11 +3,13 @@ package controller_test
 import (
 	"context"
 	"net/http"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/ghttp"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -71,6 +73,7 @@ var _ = Describe("User Controller", func() {
 
 			It("updates the status, if creation fails", func() {
 				prepareFindUser(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
 				prepareCreateUser(res, http.StatusServiceUnavailable)
 
 				result, err := reconcile(false)
@@ -85,6 +88,7 @@ var _ = Describe("User Controller", func() {
 			It("creates the user, updates status and adds a finalizer", func() {
 				res = resAfterReconciliation.DeepCopy()
 				prepareFindUser(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
 				prepareCreateUser(res, http.StatusOK)
 
 				_, err := reconcile(false)
@@ -124,14 +128,16 @@ var _ = Describe("User Controller", func() {
 			It("updates the status, if creation fails with conflict", func() {
 				res = resAfterReconciliation.DeepCopy()
 				prepareFindUser(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
 				prepareCreateUser(res, http.StatusConflict)
 
 				result, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
-				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(1))
+				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(2))
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeFalse())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeDrifted)).To(BeTrue())
 				Expect(result.RequeueAfter).To(BeNumerically(">", 0))
 			})
 		})
@@ -188,6 +194,51 @@ var _ = Describe("User Controller", func() {
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeFalse())
 				Expect(result.RequeueAfter).To(BeNumerically(">", 0))
 			})
+
+			It("does not update ignored fields", func() {
+				res = resAfterReconciliation.DeepCopy()
+				res.Spec.IgnoreFields = []string{"comment"}
+				err := k8sClient.Update(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				apiUser := res.DeepCopy()
+				apiUser.Spec.Comment = "changed in MailU"
+				prepareFindUser(apiUser, http.StatusOK)
+
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation.Spec.Comment).To(Equal(mockComment + "1"))
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+			})
+
+			It("reports drift in MailU without correcting it", func() {
+				res = resAfterReconciliation.DeepCopy()
+				res.Annotations = map[string]string{
+					AnnotationDriftPolicy:    DriftPolicyReport,
+					AnnotationResyncInterval: "1m",
+				}
+				err := k8sClient.Update(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				apiUser := res.DeepCopy()
+				apiUser.Spec.DisplayedName = "changed in MailU"
+				prepareFindUser(apiUser, http.StatusOK)
+
+				result, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(result.RequeueAfter).To(Equal(time.Minute))
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeDrifted)).To(BeTrue())
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeDrifted)
+				Expect(condition.Message).To(ContainSubstring("displayedName"))
+			})
 		})
 
 		When("deleting a User", func() {
@@ -238,14 +289,20 @@ var _ = Describe("User Controller", func() {
 				Expect(res.GetFinalizers()).To(HaveLen(0))
 				Expect(res.Status.Conditions).To(HaveLen(0))
 
-				prepareFindUser(res, http.StatusOK)
+				// the existing user was not created by the operator, so it carries no ownership marker
+				existing := res.DeepCopy()
+				existing.UID = ""
+				prepareFindUser(existing, http.StatusOK)
+				// the ownership marker is added to the comment
+				preparePatchUser(res, http.StatusOK)
 
 				_, err := reconcile(false)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(resAfterReconciliation.GetFinalizers()).To(HaveLen(1))
-				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(1))
+				Expect(resAfterReconciliation.Status.Conditions).To(HaveLen(2))
 				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeAdopted)).To(BeTrue())
 			})
 		})
 
@@ -268,5 +325,508 @@ var _ = Describe("User Controller", func() {
 				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.User{}))
 			})
 		})
+
+		When("deleting a User with deletion policy Retain", func() {
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.User{}, "retained", domain).(*operatorv1alpha1.User)
+				res.Spec.DeletionPolicy = operatorv1alpha1.DeletionPolicyRetain
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindUser(res, http.StatusOK)
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				res = resAfterReconciliation.DeepCopy()
+				err = k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("removes the finalizer without deleting the user", func() {
+				// the ownership marker is removed, so the retained user is not pruned
+				prepareFindUser(res, http.StatusOK)
+				preparePatchUser(res, http.StatusOK)
+
+				_, err := reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.User{}))
+			})
+		})
+
+		When("renaming a User", func() {
+			var previous *operatorv1alpha1.User
+
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.User{}, "renamed", domain).(*operatorv1alpha1.User)
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindUser(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
+				prepareCreateUser(res, http.StatusOK)
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resAfterReconciliation.Status.AppliedAddress).To(Equal("renamed@" + domain))
+
+				previous = resAfterReconciliation.DeepCopy()
+			})
+
+			It("rejects the rename by default", func() {
+				res = resAfterReconciliation.DeepCopy()
+				res.Spec.Name = "renamed-new"
+				err := k8sClient.Update(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(mock.ReceivedRequests()).To(BeEmpty())
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)
+				Expect(condition.Reason).To(Equal("RenameRejected"))
+				Expect(resAfterReconciliation.Status.AppliedAddress).To(Equal("renamed@" + domain))
+			})
+
+			It("creates the new user and deletes the previous one with renamePolicy Recreate", func() {
+				res = resAfterReconciliation.DeepCopy()
+				res.Spec.RenamePolicy = operatorv1alpha1.RenamePolicyRecreate
+				err := k8sClient.Update(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindUser(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
+				prepareCreateUser(res, http.StatusOK)
+				prepareDeleteUser(previous, http.StatusOK)
+
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.AppliedAddress).To(Equal("renamed-new@" + domain))
+			})
+
+			It("deletes the user at the new address", func() {
+				res = resAfterReconciliation.DeepCopy()
+				err := k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindUser(res, http.StatusOK)
+				prepareDeleteUser(res, http.StatusOK)
+
+				_, err = reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.User{}))
+			})
+		})
+
+		When("creating a User with the address of an existing Alias", func() {
+			var alias *operatorv1alpha1.Alias
+
+			BeforeAll(func() {
+				alias = CreateResource(operatorv1alpha1.Alias{}, "conflict", domain).(*operatorv1alpha1.Alias)
+				err := k8sClient.Create(ctx, alias)
+				Expect(err).ToNot(HaveOccurred())
+
+				res = CreateResource(operatorv1alpha1.User{}, "conflict", domain).(*operatorv1alpha1.User)
+				err = k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				err := k8sClient.Delete(ctx, alias)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("sets the Conflict condition without calling MailU", func() {
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(mock.ReceivedRequests()).To(BeEmpty())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeFalse())
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeConflict)
+				Expect(condition).ToNot(BeNil())
+				Expect(condition.Message).To(ContainSubstring("Alias default/conflict"))
+			})
+
+			It("removes the finalizer without calling MailU", func() {
+				res = resAfterReconciliation.DeepCopy()
+				err := k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(mock.ReceivedRequests()).To(BeEmpty())
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.User{}))
+			})
+		})
+
+		When("creating a User before its Domain", func() {
+			var waitingDomain *operatorv1alpha1.Domain
+
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.User{}, "waiting", "waiting.example.com").(*operatorv1alpha1.User)
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				err := k8sClient.Delete(ctx, waitingDomain)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("requeues the request, while the domain does not exist in MailU", func() {
+				prepareFindUser(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusNotFound)
+
+				result, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(mock.ReceivedRequests()).To(HaveLen(2))
+				Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, ConditionTypeDomainNotReady)).To(BeTrue())
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)
+				Expect(condition.Reason).To(Equal("DomainNotReady"))
+			})
+
+			It("waits for the Domain resource to become ready", func() {
+				waitingDomain = CreateResource(operatorv1alpha1.Domain{}, "waiting", "waiting.example.com").(*operatorv1alpha1.Domain)
+				err := k8sClient.Create(ctx, waitingDomain)
+				Expect(err).ToNot(HaveOccurred())
+
+				res = resAfterReconciliation.DeepCopy()
+				prepareFindUser(res, http.StatusNotFound)
+
+				result, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(mock.ReceivedRequests()).To(HaveLen(1))
+				Expect(result.RequeueAfter).To(BeNumerically("==", 0))
+				condition := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeDomainNotReady)
+				Expect(condition).ToNot(BeNil())
+				Expect(condition.Message).To(ContainSubstring("default/waiting"))
+			})
+
+			It("creates the user, once the Domain resource is ready", func() {
+				meta.SetStatusCondition(&waitingDomain.Status.Conditions, metav1.Condition{
+					Type: DomainConditionTypeReady, Status: metav1.ConditionTrue, Reason: "Created", Message: "Domain created in MailU",
+				})
+				err := k8sClient.Status().Update(ctx, waitingDomain)
+				Expect(err).ToNot(HaveOccurred())
+
+				res = resAfterReconciliation.DeepCopy()
+				prepareFindUser(res, http.StatusNotFound)
+				prepareCreateUser(res, http.StatusOK)
+
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeDomainNotReady)).To(BeNil())
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+			})
+
+			It("deletes the user", func() {
+				res = resAfterReconciliation.DeepCopy()
+				err := k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindUser(res, http.StatusOK)
+				prepareDeleteUser(res, http.StatusOK)
+
+				_, err = reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.User{}))
+			})
+		})
+
+		When("creating a User in a Domain with user defaults", func() {
+			var defaultsDomain *operatorv1alpha1.Domain
+			enabled, disabled, quota := true, false, int64(1000)
+
+			BeforeAll(func() {
+				defaultsDomain = CreateResource(operatorv1alpha1.Domain{}, "defaults", "defaults.example.com").(*operatorv1alpha1.Domain)
+				defaultsDomain.Spec.UserDefaults = &operatorv1alpha1.UserSettings{EnableIMAP: &enabled, QuotaBytes: &quota}
+				err := k8sClient.Create(ctx, defaultsDomain)
+				Expect(err).ToNot(HaveOccurred())
+				meta.SetStatusCondition(&defaultsDomain.Status.Conditions, metav1.Condition{
+					Type: DomainConditionTypeReady, Status: metav1.ConditionTrue, Reason: "Created", Message: "Domain created in MailU",
+				})
+				err = k8sClient.Status().Update(ctx, defaultsDomain)
+				Expect(err).ToNot(HaveOccurred())
+
+				res = CreateResource(operatorv1alpha1.User{}, "defaulted", "defaults.example.com").(*operatorv1alpha1.User)
+				res.Spec.EnablePOP = &disabled
+				err = k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				err := k8sClient.Delete(ctx, defaultsDomain)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("creates the user with the defaults of the domain", func() {
+				// the user is created in MailU as if it set the defaults itself
+				defaulted := res.DeepCopy()
+				defaulted.Spec.EnableIMAP = &enabled
+				defaulted.Spec.QuotaBytes = &quota
+				prepareFindUser(res, http.StatusNotFound)
+				prepareCreateUser(defaulted, http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+				settings := resAfterReconciliation.Status.Settings
+				Expect(settings).ToNot(BeNil())
+				Expect(*settings.EnableIMAP).To(BeTrue())
+				Expect(*settings.EnablePOP).To(BeFalse())
+				Expect(*settings.QuotaBytes).To(Equal(quota))
+			})
+		})
+
+		When("creating a User that is not active yet", func() {
+			activeFrom := time.Now().Add(time.Hour)
+
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.User{}, "intern", "example.com").(*operatorv1alpha1.User)
+				res.Spec.ActiveFrom = &metav1.Time{Time: activeFrom}
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("creates the user disabled and requeues at activeFrom", func() {
+				prepareFindUser(res, http.StatusNotFound)
+				prepareCreateUser(res, http.StatusOK)
+
+				result, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.RequeueAfter).To(BeNumerically("<=", time.Hour))
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.Phase).To(Equal(operatorv1alpha1.UserPhasePending))
+			})
+		})
+
+		When("creating a User that expired and is deleted on expiry", func() {
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.User{}, "contractor", "example.com").(*operatorv1alpha1.User)
+				res.Spec.ActiveUntil = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+				res.Spec.ExpiryAction = operatorv1alpha1.UserExpiryActionDelete
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not create the user", func() {
+				prepareFindUser(res, http.StatusNotFound)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				ready := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)
+				Expect(ready).ToNot(BeNil())
+				Expect(ready.Reason).To(Equal("Expired"))
+				Expect(resAfterReconciliation.Status.Phase).To(Equal(operatorv1alpha1.UserPhaseExpired))
+			})
+		})
+
+		When("creating a User with a UserClass", func() {
+			var class *operatorv1alpha1.UserClass
+			enabled, threshold := true, 80
+
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.User{}, "classified", "example.com").(*operatorv1alpha1.User)
+				res.Spec.UserClassName = "staff"
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				err := k8sClient.Delete(ctx, class)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("waits for the class to exist", func() {
+				prepareFindUser(res, http.StatusNotFound)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				ready := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)
+				Expect(ready).ToNot(BeNil())
+				Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+				Expect(ready.Reason).To(Equal("UserClassNotFound"))
+			})
+
+			It("creates the user with the settings of the class", func() {
+				class = &operatorv1alpha1.UserClass{
+					ObjectMeta: metav1.ObjectMeta{Name: "staff"},
+					Spec: operatorv1alpha1.UserClassSpec{
+						UserSettings: operatorv1alpha1.UserSettings{SpamEnabled: &enabled, SpamThreshold: &threshold},
+					},
+				}
+				err := k8sClient.Create(ctx, class)
+				Expect(err).ToNot(HaveOccurred())
+
+				// the user is created in MailU as if it set the settings of the class itself
+				classified := res.DeepCopy()
+				classified.Spec.SpamEnabled = &enabled
+				classified.Spec.SpamThreshold = &threshold
+				prepareFindUser(res, http.StatusNotFound)
+				prepareCreateUser(classified, http.StatusOK)
+
+				_, err = reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+				settings := resAfterReconciliation.Status.Settings
+				Expect(settings).ToNot(BeNil())
+				Expect(*settings.SpamEnabled).To(BeTrue())
+				Expect(*settings.SpamThreshold).To(Equal(threshold))
+			})
+		})
+
+		When("creating a User forwarding to a denied domain", func() {
+			var policy *operatorv1alpha1.ForwardingPolicy
+
+			BeforeAll(func() {
+				policy = &operatorv1alpha1.ForwardingPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "internal-only"},
+					Spec:       operatorv1alpha1.ForwardingPolicySpec{AllowedDomains: []string{"example.com"}},
+				}
+				err := k8sClient.Create(ctx, policy)
+				Expect(err).ToNot(HaveOccurred())
+
+				res = CreateResource(operatorv1alpha1.User{}, "forwarding", "example.com").(*operatorv1alpha1.User)
+				res.Spec.ForwardEnabled = true
+				res.Spec.ForwardDestination = []string{"john.doe@example.org"}
+				err = k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterAll(func() {
+				err := k8sClient.Delete(ctx, policy)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("creates the user with forwarding disabled", func() {
+				// the user is created in MailU as if it did not enable forwarding
+				disabled := res.DeepCopy()
+				disabled.Spec.ForwardEnabled = false
+				prepareFindUser(res, http.StatusNotFound)
+				prepareCreateUser(disabled, http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+				denied := meta.FindStatusCondition(resAfterReconciliation.Status.Conditions, ConditionTypeForwardingDenied)
+				Expect(denied).ToNot(BeNil())
+				Expect(denied.Message).To(ContainSubstring("ForwardingPolicy internal-only"))
+			})
+		})
+
+		When("creating a User with aliases", func() {
+			// aliasOf returns the alias of the user in MailU
+			aliasOf := func(user *operatorv1alpha1.User, name string) *operatorv1alpha1.Alias {
+				return &operatorv1alpha1.Alias{
+					ObjectMeta: *user.ObjectMeta.DeepCopy(),
+					Spec: operatorv1alpha1.AliasSpec{
+						Name: name, Domain: user.Spec.Domain, Destination: []string{user.Spec.Name + "@" + user.Spec.Domain},
+					},
+				}
+			}
+
+			BeforeAll(func() {
+				res = CreateResource(operatorv1alpha1.User{}, "aliased", domain).(*operatorv1alpha1.User)
+				res.Spec.Aliases = []string{"first.last"}
+				err := k8sClient.Create(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("creates the user and its aliases", func() {
+				prepareFindUser(res, http.StatusNotFound)
+				prepareDomainExists(res.Spec.Domain, http.StatusOK)
+				prepareCreateUser(res, http.StatusOK)
+				prepareFindAlias(aliasOf(res, "first.last"), http.StatusNotFound)
+				prepareCreateAlias(aliasOf(res, "first.last"), http.StatusOK)
+
+				_, err := reconcile(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(meta.IsStatusConditionTrue(resAfterReconciliation.Status.Conditions, UserConditionTypeReady)).To(BeTrue())
+				Expect(resAfterReconciliation.Status.Aliases).To(Equal([]operatorv1alpha1.UserAliasStatus{
+					{Address: "first.last@" + domain, State: operatorv1alpha1.UserAliasStateApplied},
+				}))
+			})
+
+			It("deletes the aliases with the user", func() {
+				res = resAfterReconciliation.DeepCopy()
+				err := k8sClient.Delete(ctx, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: res.GetName(), Namespace: res.GetNamespace()}, res)
+				Expect(err).ToNot(HaveOccurred())
+
+				prepareFindUser(res, http.StatusOK)
+				prepareDeleteAlias(aliasOf(res, "first.last"), http.StatusOK)
+				prepareDeleteUser(res, http.StatusOK)
+
+				_, err = reconcile(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(resAfterReconciliation).To(BeComparableTo(&operatorv1alpha1.User{}))
+			})
+		})
 	})
 })
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+// IndexUserClassName is the field index of Users on the name of their UserClass.
+const IndexUserClassName = "spec.userClassName"
+
+// defaultUserSettings returns the settings of a user, which are set neither on the user, nor its class or domain.
+func defaultUserSettings() *operatorv1alpha1.UserSettings {
+	allowSpoofing, enableIMAP, enablePOP, spamEnabled, spamMarkAsRead := false, false, false, false, false
+	quotaBytes, spamThreshold := int64(-1), 0
+	return &operatorv1alpha1.UserSettings{
+		AllowSpoofing:  &allowSpoofing,
+		EnableIMAP:     &enableIMAP,
+		EnablePOP:      &enablePOP,
+		QuotaBytes:     &quotaBytes,
+		SpamEnabled:    &spamEnabled,
+		SpamMarkAsRead: &spamMarkAsRead,
+		SpamThreshold:  &spamThreshold,
+	}
+}
+
+// mergeUserSettings returns the effective settings of a user: each setting is taken from the first layer that sets
+// it, falling back to the defaults of the operator.
+func mergeUserSettings(layers ...*operatorv1alpha1.UserSettings) operatorv1alpha1.UserSettings {
+	merged := operatorv1alpha1.UserSettings{}
+	for _, layer := range append(layers, defaultUserSettings()) {
+		if layer == nil {
+			continue
+		}
+		l := layer.DeepCopy()
+		merged.AllowSpoofing = firstSet(merged.AllowSpoofing, l.AllowSpoofing)
+		merged.EnableIMAP = firstSet(merged.EnableIMAP, l.EnableIMAP)
+		merged.EnablePOP = firstSet(merged.EnablePOP, l.EnablePOP)
+		merged.QuotaBytes = firstSet(merged.QuotaBytes, l.QuotaBytes)
+		merged.SpamEnabled = firstSet(merged.SpamEnabled, l.SpamEnabled)
+		merged.SpamMarkAsRead = firstSet(merged.SpamMarkAsRead, l.SpamMarkAsRead)
+		merged.SpamThreshold = firstSet(merged.SpamThreshold, l.SpamThreshold)
+	}
+	return merged
+}
+
+func firstSet[T any](cur, next *T) *T {
+	if cur != nil {
+		return cur
+	}
+	return next
+}
+
+// DomainUserDefaults returns the user defaults of the Domain resource managing the domain in MailU, if any.
+func DomainUserDefaults(ctx context.Context, c client.Reader, domain string) (*operatorv1alpha1.UserSettings, error) {
+	d, err := managingDomain(ctx, c, domain)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	return d.Spec.UserDefaults, nil
+}
+
+// enqueueDefaultedUsers returns a handler enqueueing all Users of the changed Domain, so changed user defaults are
+// applied.
+func enqueueDefaultedUsers(c client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(mapDefaultedUsers(c))
+}
+
+func mapDefaultedUsers(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		domain, ok := obj.(*operatorv1alpha1.Domain)
+		if !ok {
+			return nil
+		}
+
+		users := &operatorv1alpha1.UserList{}
+		if err := c.List(ctx, users, client.MatchingFields{IndexDomain: domain.Spec.Name}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list users of domain "+domain.Spec.Name)
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, u := range users.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: u.Namespace, Name: u.Name},
+			})
+		}
+		return requests
+	}
+}
+
+// userClassSettings returns the settings of the UserClass of the user, if it has one.
+func userClassSettings(ctx context.Context, c client.Reader, user *operatorv1alpha1.User) (*operatorv1alpha1.UserSettings, error) {
+	if user.Spec.UserClassName == "" {
+		return nil, nil
+	}
+	class := &operatorv1alpha1.UserClass{}
+	if err := c.Get(ctx, types.NamespacedName{Name: user.Spec.UserClassName}, class); err != nil {
+		return nil, err
+	}
+	return &class.Spec.UserSettings, nil
+}
+
+// enqueueClassifiedUsers returns a handler enqueueing all Users of the changed UserClass, so changed settings are
+// applied.
+func enqueueClassifiedUsers(c client.Reader) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(mapClassifiedUsers(c))
+}
+
+func mapClassifiedUsers(c client.Reader) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		users := &operatorv1alpha1.UserList{}
+		if err := c.List(ctx, users, client.MatchingFields{IndexUserClassName: obj.GetName()}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list users of class "+obj.GetName())
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, u := range users.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: u.Namespace, Name: u.Name},
+			})
+		}
+		return requests
+	}
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/pkg/mailu"
+)
+
+// userExpiryWarning is the time before the expiry of a user from which on it is in the Expiring phase.
+const userExpiryWarning = 7 * 24 * time.Hour
+
+// userPhase returns the lifecycle phase of the user at now, and the time of its next phase transition, or the zero
+// time if there is none.
+func userPhase(user *operatorv1alpha1.User, now time.Time) (operatorv1alpha1.UserPhase, time.Time) {
+	if user.Spec.ActiveFrom != nil && now.Before(user.Spec.ActiveFrom.Time) {
+		return operatorv1alpha1.UserPhasePending, user.Spec.ActiveFrom.Time
+	}
+	if user.Spec.ActiveUntil == nil {
+		return operatorv1alpha1.UserPhaseActive, time.Time{}
+	}
+
+	until := user.Spec.ActiveUntil.Time
+	if !now.Before(until) {
+		return operatorv1alpha1.UserPhaseExpired, time.Time{}
+	}
+	if warning := until.Add(-userExpiryWarning); now.Before(warning) {
+		return operatorv1alpha1.UserPhaseActive, warning
+	}
+	return operatorv1alpha1.UserPhaseExpiring, until
+}
+
+// phaseEnabled returns true if a user in the phase is enabled in MailU, as far as its spec enables it.
+func phaseEnabled(phase operatorv1alpha1.UserPhase) bool {
+	return phase == operatorv1alpha1.UserPhaseActive || phase == operatorv1alpha1.UserPhaseExpiring
+}
+
+// expiryAction returns the action applied to the user in MailU once it expired.
+func expiryAction(user *operatorv1alpha1.User) operatorv1alpha1.UserExpiryAction {
+	if user.Spec.ExpiryAction == operatorv1alpha1.UserExpiryActionDelete {
+		return operatorv1alpha1.UserExpiryActionDelete
+	}
+	return operatorv1alpha1.UserExpiryActionDisable
+}
+
+// setPhase records the applied lifecycle phase of the user, with an Event if it changed.
+func (r *UserReconciler) setPhase(user *operatorv1alpha1.User, phase operatorv1alpha1.UserPhase) {
+	previous := user.Status.Phase
+	if previous == phase {
+		return
+	}
+	user.Status.Phase = phase
+
+	email := user.Spec.Name + "@" + user.Spec.Domain
+	switch phase {
+	case operatorv1alpha1.UserPhaseExpiring:
+		until := user.Spec.ActiveUntil.UTC().Format(time.RFC3339)
+		recordEvent(r.Recorder, user, corev1.EventTypeWarning, "Expiring", string(expiryAction(user)), "User %s expires at %s", email, until)
+	case operatorv1alpha1.UserPhaseExpired:
+		recordEvent(r.Recorder, user, corev1.EventTypeWarning, "Expired", string(expiryAction(user)), "User %s expired", email)
+	case operatorv1alpha1.UserPhaseActive:
+		if previous == operatorv1alpha1.UserPhasePending || previous == operatorv1alpha1.UserPhaseExpired {
+			recordEvent(r.Recorder, user, corev1.EventTypeNormal, "Activated", "Update", "User %s is active", email)
+		}
+	}
+}
+
+// expire deletes the expired user and its aliases in MailU. The resource is kept and not ready until its activeUntil
+// time is changed.
+func (r *UserReconciler) expire(ctx context.Context, user *operatorv1alpha1.User, foundUser *mailu.User, email string) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+
+	if result := r.deleteAliases(ctx, user); result.RequeueAfter > 0 {
+		return result, nil
+	}
+	if foundUser != nil && (user.Status.ObservedGeneration > 0 || ownedBy(foundUser.Comment, user)) {
+		result, err := r.delete(ctx, user, email)
+		if err != nil || result.RequeueAfter > 0 {
+			return result, err
+		}
+		if observeOnly(user, r.ObserveOnly) {
+			return ctrl.Result{RequeueAfter: resyncInterval(user, r.ResyncInterval)}, nil
+		}
+	}
+
+	r.setPhase(user, operatorv1alpha1.UserPhaseExpired)
+	meta.SetStatusCondition(&user.Status.Conditions, getUserReadyCondition(metav1.ConditionFalse, "Expired", "User expired and was deleted in MailU"))
+	user.Status.ObservedGeneration = user.Generation
+	logr.Info("user expired")
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,65 @@
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+var aliaslog = logf.Log.WithName("alias-resource")
+
+// SetupAliasWebhookWithManager registers the webhook for Alias in the manager.
+func SetupAliasWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &operatorv1alpha1.Alias{}).
+		WithValidator(&AliasCustomValidator{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-operator-mailu-io-v1alpha1-alias,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator.mailu.io,resources=aliases,verbs=create;update,versions=v1alpha1,name=valias-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// AliasCustomValidator validates Aliases when they are created or updated.
+type AliasCustomValidator struct{}
+
+var _ admission.Validator[*operatorv1alpha1.Alias] = &AliasCustomValidator{}
+
+// ValidateCreate implements admission.Validator.
+func (v *AliasCustomValidator) ValidateCreate(_ context.Context, alias *operatorv1alpha1.Alias) (admission.Warnings, error) {
+	aliaslog.Info("validation for Alias upon creation", "name", alias.GetName())
+	return nil, v.validate(alias)
+}
+
+// ValidateUpdate implements admission.Validator.
+func (v *AliasCustomValidator) ValidateUpdate(_ context.Context, _, alias *operatorv1alpha1.Alias) (admission.Warnings, error) {
+	aliaslog.Info("validation for Alias upon update", "name", alias.GetName())
+	return nil, v.validate(alias)
+}
+
+// ValidateDelete implements admission.Validator.
+func (v *AliasCustomValidator) ValidateDelete(_ context.Context, _ *operatorv1alpha1.Alias) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *AliasCustomValidator) validate(alias *operatorv1alpha1.Alias) error {
+	specPath := field.NewPath("spec")
+
+	allErrs := validateLocalPart(alias.Spec.Name, specPath.Child("name"))
+	allErrs = append(allErrs, validateDomainName(alias.Spec.Domain, specPath.Child("domain"))...)
+	allErrs = append(allErrs, validateIgnoreFields(alias.Spec.IgnoreFields, specPath.Child("ignoreFields"))...)
+
+	if wildcard := strings.Contains(alias.Spec.Name, "%"); wildcard != alias.Spec.Wildcard {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("wildcard"), alias.Spec.Wildcard,
+			"must be true if and only if the name contains the wildcard character '%'"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(operatorv1alpha1.GroupVersion.WithKind("Alias").GroupKind(), alias.Name, allErrs)
+}
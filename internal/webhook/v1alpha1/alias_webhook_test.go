@@ -0,0 +1,64 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func TestAliasCustomValidator(t *testing.T) {
+	validator := &AliasCustomValidator{}
+
+	tests := []struct {
+		name    string
+		spec    operatorv1alpha1.AliasSpec
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			spec: operatorv1alpha1.AliasSpec{Name: "info", Domain: "example.com"},
+		},
+		{
+			name: "valid wildcard",
+			spec: operatorv1alpha1.AliasSpec{Name: "info%", Domain: "example.com", Wildcard: true},
+		},
+		{
+			name:    "wildcard without wildcard character",
+			spec:    operatorv1alpha1.AliasSpec{Name: "info", Domain: "example.com", Wildcard: true},
+			wantErr: true,
+		},
+		{
+			name:    "wildcard character without wildcard",
+			spec:    operatorv1alpha1.AliasSpec{Name: "info%", Domain: "example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			spec:    operatorv1alpha1.AliasSpec{Name: "info@example.com", Domain: "example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid domain",
+			spec:    operatorv1alpha1.AliasSpec{Name: "info", Domain: "localhost"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid ignoreFields pattern",
+			spec:    operatorv1alpha1.AliasSpec{Name: "info", Domain: "example.com", IgnoreFields: []string{"destination["}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alias := &operatorv1alpha1.Alias{ObjectMeta: metav1.ObjectMeta{Name: "alias", Namespace: "default"}, Spec: tt.spec}
+
+			_, err := validator.ValidateCreate(context.Background(), alias)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package v1alpha1
+
+import (
+	"context"
+	"text/template"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+var autoreplylog = logf.Log.WithName("autoreply-resource")
+
+// SetupAutoReplyWebhookWithManager registers the webhook for AutoReply in the manager.
+func SetupAutoReplyWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &operatorv1alpha1.AutoReply{}).
+		WithValidator(&AutoReplyCustomValidator{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-operator-mailu-io-v1alpha1-autoreply,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator.mailu.io,resources=autoreplies,verbs=create;update,versions=v1alpha1,name=vautoreply-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// AutoReplyCustomValidator validates AutoReplies when they are created or updated.
+type AutoReplyCustomValidator struct{}
+
+var _ admission.Validator[*operatorv1alpha1.AutoReply] = &AutoReplyCustomValidator{}
+
+// ValidateCreate implements admission.Validator.
+func (v *AutoReplyCustomValidator) ValidateCreate(_ context.Context, reply *operatorv1alpha1.AutoReply) (admission.Warnings, error) {
+	autoreplylog.Info("validation for AutoReply upon creation", "name", reply.GetName())
+	return nil, v.validate(reply)
+}
+
+// ValidateUpdate implements admission.Validator.
+func (v *AutoReplyCustomValidator) ValidateUpdate(_ context.Context, _, reply *operatorv1alpha1.AutoReply) (admission.Warnings, error) {
+	autoreplylog.Info("validation for AutoReply upon update", "name", reply.GetName())
+	return nil, v.validate(reply)
+}
+
+// ValidateDelete implements admission.Validator.
+func (v *AutoReplyCustomValidator) ValidateDelete(_ context.Context, _ *operatorv1alpha1.AutoReply) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *AutoReplyCustomValidator) validate(reply *operatorv1alpha1.AutoReply) error {
+	specPath := field.NewPath("spec")
+
+	allErrs := validateTemplate(reply.Spec.Subject, specPath.Child("subject"))
+	allErrs = append(allErrs, validateTemplate(reply.Spec.Body, specPath.Child("body"))...)
+	for i, w := range reply.Spec.Windows {
+		windowPath := specPath.Child("windows").Index(i)
+		if !w.End.After(w.Start.Time) {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("end"), w.End, "must be after start"))
+		}
+		allErrs = append(allErrs, validateTemplate(w.Subject, windowPath.Child("subject"))...)
+		allErrs = append(allErrs, validateTemplate(w.Body, windowPath.Child("body"))...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(operatorv1alpha1.GroupVersion.WithKind("AutoReply").GroupKind(), reply.Name, allErrs)
+}
+
+// validateTemplate requires the text to be a valid template.
+func validateTemplate(text string, fldPath *field.Path) field.ErrorList {
+	if _, err := template.New(fldPath.String()).Parse(text); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, text, "must be a valid template: "+err.Error())}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func TestAutoReplyCustomValidator(t *testing.T) {
+	validator := &AutoReplyCustomValidator{}
+
+	start := metav1.NewTime(time.Date(2024, 7, 29, 0, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(time.Date(2024, 8, 17, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name    string
+		spec    operatorv1alpha1.AutoReplySpec
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			spec: operatorv1alpha1.AutoReplySpec{UserName: "user", Subject: "Out of office",
+				Body:    `Back on {{ .End.Format "2006-01-02" }}`,
+				Windows: []operatorv1alpha1.AutoReplyWindow{{Start: start, End: end, Subject: "Vacation of {{ .Name }}"}}},
+		},
+		{
+			name: "end before start",
+			spec: operatorv1alpha1.AutoReplySpec{UserName: "user",
+				Windows: []operatorv1alpha1.AutoReplyWindow{{Start: end, End: start}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid template",
+			spec:    operatorv1alpha1.AutoReplySpec{UserName: "user", Body: "Back on {{ .End"},
+			wantErr: true,
+		},
+		{
+			name: "invalid template of window",
+			spec: operatorv1alpha1.AutoReplySpec{UserName: "user",
+				Windows: []operatorv1alpha1.AutoReplyWindow{{Start: start, End: end, Subject: "{{ end }}"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := &operatorv1alpha1.AutoReply{ObjectMeta: metav1.ObjectMeta{Name: "reply", Namespace: "default"}, Spec: tt.spec}
+
+			_, err := validator.ValidateCreate(context.Background(), reply)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package v1alpha1
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+var domainlog = logf.Log.WithName("domain-resource")
+
+// SetupDomainWebhookWithManager registers the webhook for Domain in the manager.
+// A policy restricts who may allow spoofing in the user defaults of Domains.
+func SetupDomainWebhookWithManager(mgr ctrl.Manager, policy *PrivilegedUserPolicy) error {
+	return ctrl.NewWebhookManagedBy(mgr, &operatorv1alpha1.Domain{}).
+		WithValidator(&DomainCustomValidator{Policy: policy}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-operator-mailu-io-v1alpha1-domain,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator.mailu.io,resources=domains,verbs=create;update,versions=v1alpha1,name=vdomain-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// DomainCustomValidator validates Domains when they are created or updated.
+type DomainCustomValidator struct {
+	// Policy restricts who may enable allowSpoofing in the user defaults, if set.
+	Policy *PrivilegedUserPolicy
+}
+
+var _ admission.Validator[*operatorv1alpha1.Domain] = &DomainCustomValidator{}
+
+// ValidateCreate implements admission.Validator.
+func (v *DomainCustomValidator) ValidateCreate(ctx context.Context, domain *operatorv1alpha1.Domain) (admission.Warnings, error) {
+	domainlog.Info("validation for Domain upon creation", "name", domain.GetName())
+	return nil, v.validate(ctx, nil, domain)
+}
+
+// ValidateUpdate implements admission.Validator.
+func (v *DomainCustomValidator) ValidateUpdate(ctx context.Context, old, domain *operatorv1alpha1.Domain) (admission.Warnings, error) {
+	domainlog.Info("validation for Domain upon update", "name", domain.GetName())
+	return nil, v.validate(ctx, old, domain)
+}
+
+// ValidateDelete implements admission.Validator.
+func (v *DomainCustomValidator) ValidateDelete(_ context.Context, _ *operatorv1alpha1.Domain) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *DomainCustomValidator) validate(ctx context.Context, old, domain *operatorv1alpha1.Domain) error {
+	specPath := field.NewPath("spec")
+
+	allErrs := validateDomainName(domain.Spec.Name, specPath.Child("name"))
+	allErrs = append(allErrs, validateIgnoreFields(domain.Spec.IgnoreFields, specPath.Child("ignoreFields"))...)
+	for i, alternative := range domain.Spec.Alternatives {
+		allErrs = append(allErrs, validateDomainName(alternative, specPath.Child("alternatives").Index(i))...)
+	}
+	if domain.Spec.UserDefaults != nil {
+		allErrs = append(allErrs, validateUserSettings(*domain.Spec.UserDefaults, specPath.Child("userDefaults"))...)
+	}
+	for role := range domain.Spec.RoleAliases {
+		allErrs = append(allErrs, validateLocalPart(role, specPath.Child("roleAliases").Key(role))...)
+	}
+
+	// users of the domain allowing spoofing by default are privileged just as if they allowed it themselves
+	if enabledBy(old != nil && defaultsAllowSpoofing(old), defaultsAllowSpoofing(domain)) {
+		privilegeErrs, err := v.Policy.authorize(ctx, domain.Namespace, "", []*field.Path{specPath.Child("userDefaults", "allowSpoofing")})
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = append(allErrs, privilegeErrs...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(operatorv1alpha1.GroupVersion.WithKind("Domain").GroupKind(), domain.Name, allErrs)
+}
+
+// defaultsAllowSpoofing returns true if the user defaults of the domain allow spoofing.
+func defaultsAllowSpoofing(domain *operatorv1alpha1.Domain) bool {
+	defaults := domain.Spec.UserDefaults
+	return defaults != nil && defaults.AllowSpoofing != nil && *defaults.AllowSpoofing
+}
@@ -0,0 +1,67 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+func TestDomainCustomValidator(t *testing.T) {
+	validator := &DomainCustomValidator{}
+
+	invalidThreshold := 101
+	tests := []struct {
+		name    string
+		spec    operatorv1alpha1.DomainSpec
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			spec: operatorv1alpha1.DomainSpec{Name: "example.com", Alternatives: []string{"example.org"}},
+		},
+		{
+			name:    "invalid name",
+			spec:    operatorv1alpha1.DomainSpec{Name: "example"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid alternative",
+			spec:    operatorv1alpha1.DomainSpec{Name: "example.com", Alternatives: []string{"-example.org"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid ignoreFields pattern",
+			spec:    operatorv1alpha1.DomainSpec{Name: "example.com", IgnoreFields: []string{"max[Users"}},
+			wantErr: true,
+		},
+		{
+			name: "role aliases and catch-all",
+			spec: operatorv1alpha1.DomainSpec{Name: "example.com", CatchAll: []string{"admin@example.com"},
+				RoleAliases: map[string][]string{"postmaster": {"admin@example.com"}, "dmarc-reports": {"admin@example.com"}}},
+		},
+		{
+			name: "invalid spam threshold in user defaults",
+			spec: operatorv1alpha1.DomainSpec{Name: "example.com",
+				UserDefaults: &operatorv1alpha1.UserSettings{SpamThreshold: &invalidThreshold}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid role",
+			spec:    operatorv1alpha1.DomainSpec{Name: "example.com", RoleAliases: map[string][]string{"post master": {"admin@example.com"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain := &operatorv1alpha1.Domain{ObjectMeta: metav1.ObjectMeta{Name: "domain", Namespace: "default"}, Spec: tt.spec}
+
+			_, err := validator.ValidateCreate(context.Background(), domain)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
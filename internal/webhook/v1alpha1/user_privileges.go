@@ -0,0 +1,78 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// PrivilegedUserPolicy restricts who may enable the privileged settings of users in Mailu, globalAdmin and
+// allowSpoofing. A nil policy does not restrict them.
+type PrivilegedUserPolicy struct {
+	// Namespaces in which anyone allowed to write Users may enable privileged settings.
+	Namespaces []string
+	// Client creates the SubjectAccessReviews of requests in other namespaces, which may enable privileged settings
+	// only if the requester may update the virtual subresource users/privileged in the namespace.
+	Client client.Client
+}
+
+// authorize returns an error for each of the privileged fields, if the requester of the admission request in ctx may
+// not enable privileged settings in the namespace.
+func (p *PrivilegedUserPolicy) authorize(ctx context.Context, namespace, name string, fields []*field.Path) (field.ErrorList, error) {
+	if p == nil || len(fields) == 0 || slices.Contains(p.Namespaces, namespace) {
+		return nil, nil
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range req.UserInfo.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        "update",
+				Group:       operatorv1alpha1.GroupVersion.Group,
+				Resource:    "users",
+				Subresource: "privileged",
+				Name:        name,
+			},
+			User:   req.UserInfo.Username,
+			Groups: req.UserInfo.Groups,
+			UID:    req.UserInfo.UID,
+			Extra:  extra,
+		},
+	}
+	if err := p.Client.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	if review.Status.Allowed {
+		return nil, nil
+	}
+
+	allErrs := field.ErrorList{}
+	msg := fmt.Sprintf("may only be enabled in privileged namespaces, or with permission to update users/privileged in namespace %s", namespace)
+	for _, f := range fields {
+		allErrs = append(allErrs, field.Forbidden(f, msg))
+	}
+	return allErrs, nil
+}
+
+// enabledBy returns true if the setting is enabled now, but was not enabled before. Settings that stay enabled are
+// not authorized again, so privileged users can still be changed otherwise.
+func enabledBy(before, now bool) bool {
+	return now && !before
+}
@@ -0,0 +1,130 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/internal/controller"
+)
+
+func TestPrivilegedUserPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := authorizationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	enabled := true
+	class := &operatorv1alpha1.UserClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "spoofing"},
+		Spec:       operatorv1alpha1.UserClassSpec{UserSettings: operatorv1alpha1.UserSettings{AllowSpoofing: &enabled}},
+	}
+	spoofingDomain := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "spoofing", Namespace: "mail-admins"},
+		Spec: operatorv1alpha1.DomainSpec{Name: "spoofing.org",
+			UserDefaults: &operatorv1alpha1.UserSettings{AllowSpoofing: &enabled}},
+	}
+	// only the user "admin" may update users/privileged
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(class, spoofingDomain).
+		WithIndex(&operatorv1alpha1.Domain{}, controller.IndexDomainName, func(obj client.Object) []string {
+			return []string{obj.(*operatorv1alpha1.Domain).Spec.Name}
+		}).
+		WithInterceptorFuncs(interceptor.Funcs{Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+				attributes := review.Spec.ResourceAttributes
+				review.Status.Allowed = review.Spec.User == "admin" && attributes.Resource == "users" && attributes.Subresource == "privileged"
+				return nil
+			}
+			return c.Create(ctx, obj, opts...)
+		}}).Build()
+	policy := &PrivilegedUserPolicy{Namespaces: []string{"mail-admins"}, Client: c}
+	userValidator := &UserCustomValidator{Client: c, Policy: policy}
+	domainValidator := &DomainCustomValidator{Policy: policy}
+
+	requestBy := func(username string) context.Context {
+		return admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: username}},
+		})
+	}
+	userIn := func(namespace string, mutate func(spec *operatorv1alpha1.UserSpec)) *operatorv1alpha1.User {
+		user := &operatorv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: namespace},
+			Spec:       operatorv1alpha1.UserSpec{Name: "john.doe", Domain: "example.org"},
+		}
+		mutate(&user.Spec)
+		return user
+	}
+	globalAdmin := func(spec *operatorv1alpha1.UserSpec) { spec.GlobalAdmin = true }
+
+	tests := []struct {
+		name      string
+		requester string
+		old       *operatorv1alpha1.User
+		user      *operatorv1alpha1.User
+		wantErr   bool
+	}{
+		{name: "unprivileged user", requester: "developer", user: userIn("default", func(*operatorv1alpha1.UserSpec) {})},
+		{name: "global admin", requester: "developer", user: userIn("default", globalAdmin), wantErr: true},
+		{name: "global admin by authorized requester", requester: "admin", user: userIn("default", globalAdmin)},
+		{name: "global admin in privileged namespace", requester: "developer", user: userIn("mail-admins", globalAdmin)},
+		{name: "global admin already enabled", requester: "developer", old: userIn("default", globalAdmin), user: userIn("default", globalAdmin)},
+		{name: "allow spoofing", requester: "developer", wantErr: true,
+			user: userIn("default", func(spec *operatorv1alpha1.UserSpec) { spec.AllowSpoofing = &enabled })},
+		{name: "allow spoofing through class", requester: "developer", wantErr: true,
+			user: userIn("default", func(spec *operatorv1alpha1.UserSpec) { spec.UserClassName = "spoofing" })},
+		{name: "allow spoofing through domain defaults", requester: "developer", wantErr: true,
+			user: userIn("default", func(spec *operatorv1alpha1.UserSpec) { spec.Domain = "spoofing.org" })},
+		{name: "spoofing denied by user in domain allowing it", requester: "developer",
+			user: userIn("default", func(spec *operatorv1alpha1.UserSpec) {
+				disabled := false
+				spec.Domain, spec.AllowSpoofing = "spoofing.org", &disabled
+			})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.old == nil {
+				_, err = userValidator.ValidateCreate(requestBy(tt.requester), tt.user)
+			} else {
+				_, err = userValidator.ValidateUpdate(requestBy(tt.requester), tt.old, tt.user)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// a user kept by its finalizer cannot be escalated while it is deleted
+	terminating := userIn("default", func(*operatorv1alpha1.UserSpec) {})
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	escalated := terminating.DeepCopy()
+	escalated.Spec.GlobalAdmin = true
+	if _, err := userValidator.ValidateUpdate(requestBy("developer"), terminating, escalated); err == nil {
+		t.Error("ValidateUpdate() enabling globalAdmin of a deleted user did not fail")
+	}
+
+	domain := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec: operatorv1alpha1.DomainSpec{Name: "example.com",
+			UserDefaults: &operatorv1alpha1.UserSettings{AllowSpoofing: &enabled}},
+	}
+	if _, err := domainValidator.ValidateCreate(requestBy("developer"), domain); err == nil {
+		t.Error("ValidateCreate() of a domain allowing spoofing by default did not fail")
+	}
+	if _, err := domainValidator.ValidateCreate(requestBy("admin"), domain); err != nil {
+		t.Errorf("ValidateCreate() of a domain allowing spoofing by default error = %v", err)
+	}
+}
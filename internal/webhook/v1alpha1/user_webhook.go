@@ -0,0 +1,251 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/internal/controller"
+)
+
+var userlog = logf.Log.WithName("user-resource")
+
+// SetupUserWebhookWithManager registers the webhook for User in the manager.
+// A policy restricts who may enable the privileged settings of Users.
+func SetupUserWebhookWithManager(mgr ctrl.Manager, policy *PrivilegedUserPolicy) error {
+	return ctrl.NewWebhookManagedBy(mgr, &operatorv1alpha1.User{}).
+		WithValidator(&UserCustomValidator{Client: mgr.GetClient(), Policy: policy}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-operator-mailu-io-v1alpha1-user,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator.mailu.io,resources=users,verbs=create;update,versions=v1alpha1,name=vuser-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// UserCustomValidator validates Users when they are created or updated.
+type UserCustomValidator struct {
+	// Client is used to look up the Domain of the User.
+	Client client.Reader
+	// Policy restricts who may enable globalAdmin and allowSpoofing, if set.
+	Policy *PrivilegedUserPolicy
+}
+
+var _ admission.Validator[*operatorv1alpha1.User] = &UserCustomValidator{}
+
+// ValidateCreate implements admission.Validator.
+func (v *UserCustomValidator) ValidateCreate(ctx context.Context, user *operatorv1alpha1.User) (admission.Warnings, error) {
+	userlog.Info("validation for User upon creation", "name", user.GetName())
+	return nil, v.validate(ctx, nil, user)
+}
+
+// ValidateUpdate implements admission.Validator.
+func (v *UserCustomValidator) ValidateUpdate(ctx context.Context, old, user *operatorv1alpha1.User) (admission.Warnings, error) {
+	userlog.Info("validation for User upon update", "name", user.GetName())
+	return nil, v.validate(ctx, old, user)
+}
+
+// ValidateDelete implements admission.Validator.
+func (v *UserCustomValidator) ValidateDelete(_ context.Context, _ *operatorv1alpha1.User) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *UserCustomValidator) validate(ctx context.Context, old, user *operatorv1alpha1.User) error {
+	// updates of the metadata or status, e.g. the finalizer of a deleted user, must not be rejected because the user
+	// no longer fits its domain, changes of the spec are validated even while the user is deleted
+	if old != nil && equality.Semantic.DeepEqual(old.Spec, user.Spec) {
+		return nil
+	}
+
+	specPath := field.NewPath("spec")
+
+	allErrs := validateLocalPart(user.Spec.Name, specPath.Child("name"))
+	allErrs = append(allErrs, validateDomainName(user.Spec.Domain, specPath.Child("domain"))...)
+	allErrs = append(allErrs, validateIgnoreFields(user.Spec.IgnoreFields, specPath.Child("ignoreFields"))...)
+
+	if user.Spec.PasswordSecret != "" && user.Spec.PasswordKey == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("passwordKey"), "must be set if passwordSecret is set"))
+	}
+
+	allErrs = append(allErrs, validateUserSettings(user.Spec.UserSettings, specPath)...)
+
+	allErrs = append(allErrs, validateReplyDates(user.Spec.ReplyStartDate, user.Spec.ReplyEndDate, specPath)...)
+	allErrs = append(allErrs, validateUserAliases(user.Spec.Aliases, specPath.Child("aliases"))...)
+
+	if from, until := user.Spec.ActiveFrom, user.Spec.ActiveUntil; from != nil && until != nil && !until.After(from.Time) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("activeUntil"), until, "must be after activeFrom"))
+	}
+
+	// the quota is only checked against the domain, if it may have changed
+	if old == nil || quotaChanged(old, user) {
+		quotaErrs, err := v.validateQuota(ctx, user, specPath.Child("quotaBytes"))
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = append(allErrs, quotaErrs...)
+	}
+
+	privilegeErrs, err := v.validatePrivileges(ctx, old, user, specPath)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	allErrs = append(allErrs, privilegeErrs...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(operatorv1alpha1.GroupVersion.WithKind("User").GroupKind(), user.Name, allErrs)
+}
+
+// validateReplyDates requires the start date of auto-replies to be before the end date.
+func validateReplyDates(start, end string, specPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	startDate, err := time.Parse(dateLayout, start)
+	if start != "" && err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replyStartDate"), start, "must be a date (YYYY-MM-DD)"))
+	}
+	endDate, err := time.Parse(dateLayout, end)
+	if end != "" && err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replyEndDate"), end, "must be a date (YYYY-MM-DD)"))
+	}
+
+	if len(allErrs) == 0 && start != "" && end != "" && startDate.After(endDate) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replyStartDate"), start, "must not be after replyEndDate"))
+	}
+	return allErrs
+}
+
+// validateUserSettings requires the SPAM threshold to be a percentage, if it is set.
+func validateUserSettings(settings operatorv1alpha1.UserSettings, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if settings.SpamThreshold != nil && (*settings.SpamThreshold < 0 || *settings.SpamThreshold > 100) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("spamThreshold"), *settings.SpamThreshold, "must be between 0 and 100"))
+	}
+	return allErrs
+}
+
+// validateUserAliases requires each alias to be a local part, or an e-mail address in another domain.
+func validateUserAliases(aliases []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, alias := range aliases {
+		localPart, domain, found := strings.Cut(alias, "@")
+		allErrs = append(allErrs, validateLocalPart(localPart, fldPath.Index(i))...)
+		if found {
+			allErrs = append(allErrs, validateDomainName(domain, fldPath.Index(i))...)
+		}
+	}
+	return allErrs
+}
+
+// quotaChanged returns true if the quota, the domain or the class of the user changed.
+func quotaChanged(old, user *operatorv1alpha1.User) bool {
+	return !equality.Semantic.DeepEqual(old.Spec.QuotaBytes, user.Spec.QuotaBytes) ||
+		old.Spec.Domain != user.Spec.Domain || old.Spec.UserClassName != user.Spec.UserClassName
+}
+
+// validateQuota requires the quota to be within the maxQuotaBytes of the Domain, if it is managed by a resource.
+func (v *UserCustomValidator) validateQuota(ctx context.Context, user *operatorv1alpha1.User, fldPath *field.Path) (field.ErrorList, error) {
+	if v.Client == nil {
+		return nil, nil
+	}
+
+	domains := &operatorv1alpha1.DomainList{}
+	if err := v.Client.List(ctx, domains, client.MatchingFields{controller.IndexDomainName: user.Spec.Domain}); err != nil {
+		return nil, err
+	}
+
+	// an unset quota is taken from the class of the user first, a missing class is reported by the controller
+	class := &operatorv1alpha1.UserClass{}
+	if user.Spec.UserClassName != "" {
+		if err := v.Client.Get(ctx, client.ObjectKey{Name: user.Spec.UserClassName}, class); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+	}
+
+	allErrs := field.ErrorList{}
+	for _, domain := range domains.Items {
+		maxQuota := int64(domain.Spec.MaxQuotaBytes)
+		if maxQuota <= 0 {
+			// unlimited
+			continue
+		}
+		// then from the user defaults of the domain, and is unlimited if not set there either
+		quota := int64(-1)
+		if user.Spec.QuotaBytes != nil {
+			quota = *user.Spec.QuotaBytes
+		} else if class.Spec.QuotaBytes != nil {
+			quota = *class.Spec.QuotaBytes
+		} else if domain.Spec.UserDefaults != nil && domain.Spec.UserDefaults.QuotaBytes != nil {
+			quota = *domain.Spec.UserDefaults.QuotaBytes
+		}
+		if quota < 0 || quota > maxQuota {
+			msg := fmt.Sprintf("must be at most %d, the maxQuotaBytes of Domain %s/%s", maxQuota, domain.Namespace, domain.Name)
+			allErrs = append(allErrs, field.Invalid(fldPath, quota, msg))
+		}
+	}
+	return allErrs, nil
+}
+
+// validatePrivileges requires the requester to be authorized by the policy, if the user enables globalAdmin or
+// allowSpoofing. allowSpoofing is also enabled through the class of the user or the user defaults of its Domain.
+func (v *UserCustomValidator) validatePrivileges(ctx context.Context, old, user *operatorv1alpha1.User, specPath *field.Path) (field.ErrorList, error) {
+	if v.Policy == nil {
+		return nil, nil
+	}
+
+	fields := []*field.Path{}
+	if enabledBy(old != nil && old.Spec.GlobalAdmin, user.Spec.GlobalAdmin) {
+		fields = append(fields, specPath.Child("globalAdmin"))
+	}
+	before, err := v.allowsSpoofing(ctx, old)
+	if err != nil {
+		return nil, err
+	}
+	now, err := v.allowsSpoofing(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if enabledBy(before, now) {
+		fields = append(fields, specPath.Child("allowSpoofing"))
+	}
+
+	return v.Policy.authorize(ctx, user.Namespace, user.Name, fields)
+}
+
+// allowsSpoofing returns true if spoofing is allowed by the effective settings of the user: it is taken from the user,
+// its class or the user defaults of its Domain, whichever sets it first.
+func (v *UserCustomValidator) allowsSpoofing(ctx context.Context, user *operatorv1alpha1.User) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if user.Spec.AllowSpoofing != nil {
+		return *user.Spec.AllowSpoofing, nil
+	}
+	if v.Client == nil {
+		return false, nil
+	}
+
+	if user.Spec.UserClassName != "" {
+		class := &operatorv1alpha1.UserClass{}
+		if err := v.Client.Get(ctx, client.ObjectKey{Name: user.Spec.UserClassName}, class); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		if class.Spec.AllowSpoofing != nil {
+			return *class.Spec.AllowSpoofing, nil
+		}
+	}
+
+	defaults, err := controller.DomainUserDefaults(ctx, v.Client, user.Spec.Domain)
+	if err != nil || defaults == nil || defaults.AllowSpoofing == nil {
+		return false, err
+	}
+	return *defaults.AllowSpoofing, nil
+}
@@ -0,0 +1,225 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operatorv1alpha1 "github.com/sickhub/mailu-operator/api/v1alpha1"
+	"github.com/sickhub/mailu-operator/internal/controller"
+)
+
+func TestUserCustomValidator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := operatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	domain := &operatorv1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec: operatorv1alpha1.DomainSpec{Name: "example.com", MaxQuotaBytes: 1000,
+			UserDefaults: &operatorv1alpha1.UserSettings{QuotaBytes: toInt64(500)}},
+	}
+	class := &operatorv1alpha1.UserClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "large"},
+		Spec:       operatorv1alpha1.UserClassSpec{UserSettings: operatorv1alpha1.UserSettings{QuotaBytes: toInt64(2000)}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(domain, class).
+		WithIndex(&operatorv1alpha1.Domain{}, controller.IndexDomainName, func(obj client.Object) []string {
+			return []string{obj.(*operatorv1alpha1.Domain).Spec.Name}
+		}).Build()
+	validator := &UserCustomValidator{Client: c}
+
+	unlimited, threshold := int64(-1), 80
+	valid := operatorv1alpha1.UserSpec{
+		Name:           "john.doe",
+		Domain:         "example.org",
+		UserSettings:   operatorv1alpha1.UserSettings{QuotaBytes: &unlimited, SpamThreshold: &threshold},
+		ReplyStartDate: "2024-01-01",
+		ReplyEndDate:   "2024-01-31",
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(spec *operatorv1alpha1.UserSpec)
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {},
+		},
+		{
+			name:    "invalid name",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.Name = "john doe" },
+			wantErr: true,
+		},
+		{
+			name:    "invalid domain",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.Domain = "example_org" },
+			wantErr: true,
+		},
+		{
+			name:   "ignoreFields patterns",
+			mutate: func(spec *operatorv1alpha1.UserSpec) { spec.IgnoreFields = []string{"reply*", "spamThreshold"} },
+		},
+		{
+			name:    "invalid ignoreFields pattern",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.IgnoreFields = []string{"reply["} },
+			wantErr: true,
+		},
+		{
+			name:    "password secret without key",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.PasswordSecret = "passwords" },
+			wantErr: true,
+		},
+		{
+			name: "password secret with key",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.PasswordSecret = "passwords"
+				spec.PasswordKey = "john.doe"
+			},
+		},
+		{
+			name:   "aliases",
+			mutate: func(spec *operatorv1alpha1.UserSpec) { spec.Aliases = []string{"first.last", "info@example.com"} },
+		},
+		{
+			name:    "invalid alias",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.Aliases = []string{"first last"} },
+			wantErr: true,
+		},
+		{
+			name:    "invalid alias domain",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.Aliases = []string{"info@example_org"} },
+			wantErr: true,
+		},
+		{
+			name:    "spam threshold too high",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.SpamThreshold = toInt(101) },
+			wantErr: true,
+		},
+		{
+			name:    "reply start after end",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.ReplyStartDate = "2024-02-01" },
+			wantErr: true,
+		},
+		{
+			name:    "invalid reply date",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.ReplyEndDate = "31.01.2024" },
+			wantErr: true,
+		},
+		{
+			name: "active period",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.ActiveFrom = &metav1.Time{Time: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)}
+				spec.ActiveUntil = &metav1.Time{Time: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)}
+			},
+		},
+		{
+			name: "activeUntil before activeFrom",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.ActiveFrom = &metav1.Time{Time: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)}
+				spec.ActiveUntil = &metav1.Time{Time: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)}
+			},
+			wantErr: true,
+		},
+		{
+			name: "quota within domain",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.Domain = "example.com"
+				spec.QuotaBytes = toInt64(1000)
+			},
+		},
+		{
+			name: "quota exceeds domain",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.Domain = "example.com"
+				spec.QuotaBytes = toInt64(1001)
+			},
+			wantErr: true,
+		},
+		{
+			name: "quota from domain defaults",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.Domain = "example.com"
+				spec.QuotaBytes = nil
+			},
+		},
+		{
+			name: "quota from class exceeds domain",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.Domain = "example.com"
+				spec.QuotaBytes = nil
+				spec.UserClassName = "large"
+			},
+			wantErr: true,
+		},
+		{
+			name: "quota of missing class",
+			mutate: func(spec *operatorv1alpha1.UserSpec) {
+				spec.Domain = "example.com"
+				spec.QuotaBytes = nil
+				spec.UserClassName = "missing"
+			},
+		},
+		{
+			name:    "unlimited quota in limited domain",
+			mutate:  func(spec *operatorv1alpha1.UserSpec) { spec.Domain = "example.com" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &operatorv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "default"}, Spec: valid}
+			tt.mutate(&user.Spec)
+
+			_, err := validator.ValidateCreate(context.Background(), user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			old := &operatorv1alpha1.User{ObjectMeta: user.ObjectMeta, Spec: valid}
+			_, err = validator.ValidateUpdate(context.Background(), old, user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// a user that no longer fits its domain, e.g. after its maxQuotaBytes was lowered, can still be updated otherwise
+	// and deleted
+	unfit := &operatorv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "default"}, Spec: valid}
+	unfit.Spec.Domain = "example.com"
+	updated := unfit.DeepCopy()
+	updated.Finalizers = []string{controller.FinalizerName}
+	if _, err := validator.ValidateUpdate(context.Background(), unfit, updated); err != nil {
+		t.Errorf("ValidateUpdate() of unchanged spec error = %v", err)
+	}
+	updated.Spec.DisplayedName = "John Doe"
+	if _, err := validator.ValidateUpdate(context.Background(), unfit, updated); err != nil {
+		t.Errorf("ValidateUpdate() without changed quota error = %v", err)
+	}
+	deleted := updated.DeepCopy()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	released := deleted.DeepCopy()
+	released.Finalizers = nil
+	if _, err := validator.ValidateUpdate(context.Background(), deleted, released); err != nil {
+		t.Errorf("ValidateUpdate() removing the finalizer of deleted user error = %v", err)
+	}
+	changed := deleted.DeepCopy()
+	changed.Spec.Name = "john doe"
+	if _, err := validator.ValidateUpdate(context.Background(), deleted, changed); err == nil {
+		t.Error("ValidateUpdate() of the spec of deleted user did not fail")
+	}
+}
+
+func toInt(v int) *int {
+	return &v
+}
+
+func toInt64(v int64) *int64 {
+	return &v
+}
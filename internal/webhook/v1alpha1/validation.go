@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// localPartRegexp matches the local part of an e-mail address (dot-atom of RFC 5322).
+var localPartRegexp = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+/=?^_`{|}~-]+(\\.[A-Za-z0-9!#$%&'*+/=?^_`{|}~-]+)*$")
+
+// dateLayout is the format of dates in the specs.
+const dateLayout = "2006-01-02"
+
+// validateLocalPart validates the local part of an e-mail address, the part before the '@'.
+func validateLocalPart(name string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(name) > 64 {
+		allErrs = append(allErrs, field.TooLong(fldPath, name, 64))
+	}
+	if !localPartRegexp.MatchString(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must be a valid local part of an e-mail address"))
+	}
+	return allErrs
+}
+
+// validateDomainName validates a fully qualified domain name.
+func validateDomainName(name string, fldPath *field.Path) field.ErrorList {
+	return validation.IsFullyQualifiedDomainName(fldPath, strings.ToLower(name))
+}
+
+// validateIgnoreFields validates the patterns of ignoreFields, as a malformed pattern would ignore nothing.
+func validateIgnoreFields(patterns []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), pattern, "must be a valid pattern, e.g. 'reply*'"))
+		}
+	}
+	return allErrs
+}